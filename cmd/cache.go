@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zapstore/zapstore/install"
+	"github.com/zapstore/zapstore/ui"
+)
+
+// Default limits for `cache prune`.
+const (
+	defaultCacheMaxSize = "1G"
+	defaultCacheMaxAge  = "90d"
+)
+
+// Cache manages the download cache. Subcommands: ls, prune, clear.
+func Cache(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: zapstore cache ls|prune|clear")
+	}
+
+	switch args[0] {
+	case "ls":
+		return cacheList()
+	case "prune":
+		return cachePrune(args[1:])
+	case "clear":
+		return cacheClear()
+	default:
+		return fmt.Errorf("unknown cache command: %s", args[0])
+	}
+}
+
+func cacheList() error {
+	blobs, err := install.CacheList()
+	if err != nil {
+		return fmt.Errorf("reading cache: %w", err)
+	}
+
+	if ui.JSON {
+		return cacheListJSON(blobs)
+	}
+
+	if len(blobs) == 0 {
+		ui.Infof("Cache is empty.")
+		return nil
+	}
+
+	var total int64
+	maxSize := len("SIZE")
+	for _, b := range blobs {
+		total += b.Size
+		if n := len(ui.FormatBytes(b.Size)); n > maxSize {
+			maxSize = n
+		}
+	}
+
+	fmt.Println()
+	ui.TableHeader(
+		[]int{64, maxSize, 19},
+		"SHA-256", "SIZE", "LAST USED",
+	)
+
+	for _, b := range blobs {
+		fmt.Printf("%-64s  %*s  %s\n",
+			b.Hash,
+			maxSize, ui.FormatBytes(b.Size),
+			ui.Dim(b.LastUsed.UTC().Format(time.RFC3339)[:19]),
+		)
+	}
+
+	fmt.Printf("\n%s\n", ui.Dim(fmt.Sprintf("%d blob(s), %s total.", len(blobs), ui.FormatBytes(total))))
+	return nil
+}
+
+// blobJSON is the JSON form of a cached download in cache ls.
+type blobJSON struct {
+	Hash     string `json:"hash"`
+	Size     int64  `json:"size"`
+	LastUsed string `json:"last_used"` // RFC 3339, UTC
+}
+
+func cacheListJSON(blobs []install.Blob) error {
+	out := []blobJSON{}
+	var total int64
+	for _, b := range blobs {
+		out = append(out, blobJSON{Hash: b.Hash, Size: b.Size, LastUsed: b.LastUsed.UTC().Format(time.RFC3339)})
+		total += b.Size
+	}
+	return ui.PrintJSON(map[string]any{"blobs": out, "total_size": total})
+}
+
+func cachePrune(args []string) error {
+	fs := flag.NewFlagSet("cache prune", flag.ContinueOnError)
+	maxSizeFlag := fs.String("max-size", defaultCacheMaxSize, "keep the cache under this size (e.g. 500M, 2G)")
+	maxAgeFlag := fs.String("max-age", defaultCacheMaxAge, "remove blobs not used within this long (e.g. 30d, 12h)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	maxSize, err := parseSize(*maxSizeFlag)
+	if err != nil {
+		return fmt.Errorf("invalid --max-size: %w", err)
+	}
+	maxAge, err := parseAge(*maxAgeFlag)
+	if err != nil {
+		return fmt.Errorf("invalid --max-age: %w", err)
+	}
+
+	sp := ui.NewSpinner("Pruning cache...")
+	sp.Start()
+
+	removed, bytesFreed, err := install.CachePrune(maxSize, maxAge)
+	if err != nil {
+		sp.StopWithError("Prune failed")
+		return fmt.Errorf("pruning cache: %w", err)
+	}
+
+	if removed == 0 {
+		sp.StopWithSuccess("Nothing to prune")
+	} else {
+		sp.StopWithSuccess(fmt.Sprintf("Removed %d cached file(s), freed %s", removed, ui.FormatBytes(bytesFreed)))
+	}
+	return printRemoved(removed, bytesFreed)
+}
+
+func cacheClear() error {
+	sp := ui.NewSpinner("Clearing cache...")
+	sp.Start()
+
+	removed, bytesFreed, err := install.CacheClear()
+	if err != nil {
+		sp.StopWithError("Clear failed")
+		return fmt.Errorf("clearing cache: %w", err)
+	}
+
+	sp.StopWithSuccess(fmt.Sprintf("Removed %d cached file(s), freed %s", removed, ui.FormatBytes(bytesFreed)))
+	return printRemoved(removed, bytesFreed)
+}
+
+// parseSize parses a byte size with an optional K, M, G or T suffix
+// (powers of 1024; a trailing "B" or "iB" is accepted). "0" disables the limit.
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+
+	mult := int64(1)
+	if n := len(s); n > 0 {
+		if i := strings.IndexByte("KMGT", s[n-1]); i != -1 {
+			mult = int64(1) << (10 * (i + 1))
+			s = s[:n-1]
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a size", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// parseAge parses a Go duration, additionally accepting a "d" suffix for
+// days. "0" disables the limit.
+func parseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a duration", s)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/zapstore/zapstore/store"
+	"github.com/zapstore/zapstore/ui"
+	"github.com/zapstore/zapstore/validate"
+	"github.com/zapstore/zapstore/version"
+)
+
+// Channel shows or sets the release channels packages follow.
+//
+//	channel                    show the global channel and per-app overrides
+//	channel <name>             set the global channel
+//	channel <app-id>           show the channel one package follows
+//	channel <app-id> <name>    set one package's channel; "default" clears it
+func Channel(args []string) error {
+	switch len(args) {
+	case 0:
+		return channelShow()
+	case 1:
+		return channelOne(args[0])
+	case 2:
+		return channelSetApp(args[0], args[1])
+	default:
+		return fmt.Errorf("usage: zapstore channel [<app-id>] [<name>]")
+	}
+}
+
+func channelShow() error {
+	config, err := store.LoadConfig()
+	if err != nil {
+		return err
+	}
+	state, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	overrides := make(map[string]string)
+	for id, pkg := range state.Packages {
+		if pkg.Channel != "" {
+			overrides[id] = pkg.Channel
+		}
+	}
+	if ui.JSON {
+		return ui.PrintJSON(map[string]any{"channel": config.ChannelFor(nil), "packages": overrides})
+	}
+
+	ui.Infof("Channel %s", ui.Bold(config.ChannelFor(nil)))
+	for _, id := range slices.Sorted(maps.Keys(overrides)) {
+		ui.Infof("%s %s", id, ui.Dim(overrides[id]))
+	}
+	return nil
+}
+
+// channelOne handles a single argument, which names either an installed
+// package or a channel. Installed packages are checked first, since app IDs
+// such as jq are valid channel names too.
+func channelOne(arg string) error {
+	state, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+	if pkg := state.Get(arg); pkg != nil {
+		return channelShowApp(arg, pkg)
+	}
+	if _, err := version.ParseChannel(arg); err != nil && validate.AppID(arg) == nil {
+		return &NotInstalledError{AppID: arg}
+	}
+	return channelSetGlobal(arg)
+}
+
+func channelShowApp(appID string, pkg *store.Package) error {
+	if pkg.Channel == "" {
+		config, err := store.LoadConfig()
+		if err != nil {
+			return err
+		}
+		ui.Infof("%s follows the global channel %s", appID, ui.Dim("("+config.ChannelFor(nil)+")"))
+	} else {
+		ui.Infof("%s follows %s %s", appID, ui.Bold(pkg.Channel), ui.Dim(channelHint(pkg.Channel)))
+	}
+	return printPackage("package", appID, pkg)
+}
+
+func channelSetGlobal(name string) error {
+	channel, err := version.ParseChannel(name)
+	if err != nil {
+		return err
+	}
+
+	config, err := store.LoadConfig()
+	if err != nil {
+		return err
+	}
+	config.Channel = channel
+	if channel == version.Stable {
+		config.Channel = ""
+	}
+	if err := config.Save(); err != nil {
+		return err
+	}
+
+	ui.Successf("Following %s %s", ui.Bold(channel), ui.Dim(channelHint(channel)))
+	if ui.JSON {
+		return channelShow()
+	}
+	return nil
+}
+
+func channelSetApp(appID, name string) error {
+	state, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+	pkg := state.Get(appID)
+	if pkg == nil {
+		return &NotInstalledError{AppID: appID}
+	}
+
+	channel := ""
+	if name != "default" {
+		if channel, err = version.ParseChannel(name); err != nil {
+			return err
+		}
+	}
+	pkg.Channel = channel
+	if err := state.Save(); err != nil {
+		return fmt.Errorf("saving state: %w", err)
+	}
+
+	if channel == "" {
+		config, err := store.LoadConfig()
+		if err != nil {
+			return err
+		}
+		ui.Successf("%s follows the global channel %s", appID, ui.Dim("("+config.ChannelFor(nil)+")"))
+		return printPackage("package", appID, pkg)
+	}
+	ui.Successf("%s follows %s %s", appID, ui.Bold(channel), ui.Dim(channelHint(channel)))
+	return printPackage("package", appID, pkg)
+}
+
+// channelHint describes which releases a channel takes.
+func channelHint(channel string) string {
+	switch channel {
+	case version.Stable:
+		return "(stable releases only)"
+	case version.AnyPre:
+		return "(every pre-release)"
+	}
+	return fmt.Sprintf("(stable and %s releases)", channel)
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zapstore/zapstore/store"
+)
+
+func TestChannelOneArg(t *testing.T) {
+	for _, env := range []string{"XDG_STATE_HOME", "XDG_DATA_HOME", "XDG_CONFIG_HOME", "XDG_CACHE_HOME"} {
+		t.Setenv(env, t.TempDir())
+	}
+	state := &store.State{Packages: map[string]*store.Package{}}
+	state.Add("jq", &store.Package{Version: "1.7.1", Channel: "rc"})
+	state.Add("com.example.tool", &store.Package{Version: "1.4.2"})
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		arg      string
+		out      string
+		notFound bool
+		global   string // the global channel afterwards
+	}{
+		// Installed app IDs are shown, even when they read as channel names
+		{"jq", "jq follows rc", false, ""},
+		{"com.example.tool", "com.example.tool follows the global channel", false, ""},
+		{"com.example.other", "", true, ""},
+		{"beta", "Following beta", false, "beta"},
+	}
+	for _, tt := range tests {
+		out, err := captureStdout(t, func() error { return Channel([]string{tt.arg}) })
+		var nie *NotInstalledError
+		if tt.notFound {
+			if !errors.As(err, &nie) {
+				t.Errorf("channel %s = %v, want NotInstalledError", tt.arg, err)
+			}
+		} else if err != nil {
+			t.Errorf("channel %s: %v", tt.arg, err)
+		}
+		if !strings.Contains(out, tt.out) {
+			t.Errorf("channel %s printed %q, want it to contain %q", tt.arg, out, tt.out)
+		}
+		config, err := store.LoadConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Channel != tt.global {
+			t.Errorf("after channel %s the global channel is %q, want %q", tt.arg, config.Channel, tt.global)
+		}
+	}
+}
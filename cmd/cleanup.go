@@ -20,22 +20,18 @@ func Cleanup() error {
 
 	if removed == 0 {
 		sp.StopWithSuccess("Nothing to clean up")
-		return nil
+	} else {
+		sp.StopWithSuccess(fmt.Sprintf("Removed %d old version(s), freed %s", removed, ui.FormatBytes(bytesFreed)))
 	}
-
-	sp.StopWithSuccess(fmt.Sprintf("Removed %d old version(s), freed %s", removed, formatBytes(bytesFreed)))
-	return nil
+	return printRemoved(removed, bytesFreed)
 }
 
-func formatBytes(b int64) string {
-	const unit = 1024
-	if b < unit {
-		return fmt.Sprintf("%d B", b)
-	}
-	div, exp := int64(unit), 0
-	for n := b / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+// printRemoved prints the JSON document of commands that delete files:
+// how many were removed and the bytes freed. It prints nothing outside
+// JSON mode.
+func printRemoved(removed int, bytesFreed int64) error {
+	if !ui.JSON {
+		return nil
 	}
-	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
+	return ui.PrintJSON(map[string]any{"removed": removed, "bytes_freed": bytesFreed})
 }
@@ -3,6 +3,8 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/zapstore/zapstore/install"
@@ -13,13 +15,46 @@ import (
 	"github.com/zapstore/zapstore/version"
 )
 
-// Install resolves an app from the relay, downloads, verifies, and installs it.
-func Install(appID string) error {
+// installJSON is the JSON form of an install's outcome.
+type installJSON struct {
+	AppID       string   `json:"app_id"`
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	Previous    string   `json:"previous_version"` // "" on a fresh install
+	Status      string   `json:"status"`           // installed, upgraded, downgraded or unchanged
+	Pubkey      string   `json:"pubkey"`
+	Executables []string `json:"executables"`
+	Path        string   `json:"path"` // link to the main executable, "" if there is none
+}
+
+// Install resolves an app from the client's relays, downloads, verifies, and
+// installs it.
+// spec is an app ID, optionally followed by @<version> to install that exact
+// release, or by a constraint such as @^1.2 to install the highest matching
+// one. A range constraint is recorded so later installs and updates stay
+// within it; an exact version or @* clears it. Releases come from the
+// package's channel (stable unless configured otherwise); pre allows any
+// pre-release for this install only.
+func Install(client *nostr.Client, spec string, pre bool) error {
+	appID, want := splitSpec(spec)
+
+	// An explicit version may also downgrade; check it before going online
+	var explicit *version.Constraint
+	if want != "" {
+		c, err := version.ParseSpec(want)
+		if err != nil {
+			return err
+		}
+		explicit = c
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
 	plat := platform.Detect()
-	fmt.Printf("  %s %s\n", ui.Dim("platform"), plat.Platform)
+	if !ui.JSON {
+		fmt.Printf("  %s %s\n", ui.Dim("platform"), plat.Platform)
+	}
 
 	// Check if already installed
 	state, err := store.Load()
@@ -27,35 +62,92 @@ func Install(appID string) error {
 		return fmt.Errorf("loading state: %w", err)
 	}
 
+	trust, err := store.LoadTrust()
+	if err != nil {
+		return err
+	}
+	trust.Seed(state)
+
+	config, err := store.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	// Without an explicit version, stay within the recorded constraint
+	pkg := state.Get(appID)
+	recorded := want
+	if explicit == nil && pkg != nil {
+		want, recorded = pkg.Constraint, pkg.Constraint
+	}
+	if explicit != nil && (explicit.IsExact() || explicit.IsAny()) {
+		recorded = ""
+	}
+
+	channel := config.ChannelFor(pkg)
+	if pre {
+		channel = version.AnyPre
+	}
+
 	// Resolve: app → release → asset
 	sp := ui.NewSpinner(fmt.Sprintf("Resolving %s...", appID))
 	sp.Start()
 
-	app, release, asset, err := nostr.Resolve(ctx, nostr.RelayURL(), appID, plat)
+	app, release, asset, err := client.Resolve(ctx, appID, trust.Publisher(appID), want, channel, plat)
 	if err != nil {
 		sp.StopWithError(fmt.Sprintf("Failed to resolve %s", appID))
 		return err
 	}
 	sp.StopWithSuccess(fmt.Sprintf("Found %s %s", ui.Bold(app.Name), ui.Dim("v"+release.Version)))
 
+	// Refuse a publisher other than the one trusted on first install
+	if err := trust.Check(appID, app.Pubkey); err != nil {
+		return err
+	}
+
+	out := installJSON{
+		AppID:   appID,
+		Name:    app.Name,
+		Version: release.Version,
+		Status:  "installed",
+		Pubkey:  app.Pubkey,
+	}
+
 	// Check if same or newer version already installed
-	if pkg := state.Get(appID); pkg != nil {
-		if !version.CanUpgrade(pkg.Version, release.Version) {
+	if pkg != nil {
+		out.Previous = pkg.Version
+		switch c := version.Compare(release.Version, pkg.Version); {
+		case c == 0 && explicit != nil && explicit.Check(pkg.Version):
+			ui.Infof("Already installed %s", ui.Dim("(v"+pkg.Version+")"))
+			if err := setConstraint(state, pkg, recorded); err != nil {
+				return err
+			}
+			return printUnchanged(out, pkg)
+		case c <= 0 && explicit == nil:
 			ui.Infof("Already up to date %s", ui.Dim("(v"+pkg.Version+")"))
-			return nil
+			return printUnchanged(out, pkg)
+		case c < 0:
+			ui.Infof("Downgrading %s %s %s", ui.Dim("v"+pkg.Version), ui.Arrow(), ui.Dim("v"+release.Version))
+			out.Status = "downgraded"
+		default:
+			ui.Infof("Upgrading %s %s %s", ui.Dim("v"+pkg.Version), ui.Arrow(), ui.Dim("v"+release.Version))
+			out.Status = "upgraded"
 		}
-		ui.Infof("Upgrading %s %s %s", ui.Dim("v"+pkg.Version), ui.Arrow(), ui.Dim("v"+release.Version))
 	}
 
 	// Install
 	result, err := install.Run(install.Options{
-		AppID:    appID,
-		Version:  release.Version,
-		URL:      asset.URL,
-		Hash:     asset.Hash,
-		Filename: asset.Filename,
-		Pubkey:   app.Pubkey,
-		EventID:  asset.Event.ID,
+		AppID:       appID,
+		Version:     release.Version,
+		URL:         asset.URL,
+		Hash:        asset.Hash,
+		Filename:    asset.Filename,
+		Size:        asset.Size,
+		MIME:        asset.MIME,
+		Executables: asset.Executables,
+		Pubkey:      app.Pubkey,
+		EventID:     asset.Event.ID,
+		Offline:     nostr.Offline,
+		Previous:    pkg,
 	})
 	if err != nil {
 		return err
@@ -65,14 +157,73 @@ func Install(appID string) error {
 	state.Add(appID, &store.Package{
 		Pubkey:       app.Pubkey,
 		Version:      release.Version,
-		Executables:  []string{result.BinaryName},
+		Executables:  result.Executables,
+		Extras:       result.Extras,
 		AssetEventID: asset.Event.ID,
+		Constraint:   recorded,
+		Links:        result.Links,
 	})
 
 	if err := state.Save(); err != nil {
+		if rerr := result.Rollback(); rerr != nil {
+			ui.Errorf("rollback: %v", rerr)
+		}
 		return fmt.Errorf("saving state: %w", err)
 	}
+	result.Commit(state.Get(appID).Versions())
 
+	if trust.Get(appID) == nil {
+		trust.Pin(appID, app.Pubkey)
+		if err := trust.Save(); err != nil {
+			return fmt.Errorf("saving trust database: %w", err)
+		}
+	}
+
+	if ui.JSON {
+		out.Executables = nonNil(result.Executables)
+		out.Path = result.SymlinkPath
+		return ui.PrintJSON(out)
+	}
 	ui.Resultf("Installed %s v%s %s %s", app.Name, release.Version, ui.Arrow(), ui.Dim(result.SymlinkPath))
 	return nil
 }
+
+// printUnchanged prints the JSON result of an install that left the
+// installed version in place.
+func printUnchanged(out installJSON, pkg *store.Package) error {
+	if !ui.JSON {
+		return nil
+	}
+	out.Version = pkg.Version
+	out.Status = "unchanged"
+	out.Executables = nonNil(pkg.Executables)
+	if dataDir, err := store.DataDir(); err == nil && len(pkg.Executables) > 0 {
+		out.Path = filepath.Join(dataDir, "bin", pkg.Executables[0])
+	}
+	return ui.PrintJSON(out)
+}
+
+// setConstraint records a new constraint for an installed package.
+func setConstraint(state *store.State, pkg *store.Package, constraint string) error {
+	if pkg.Constraint == constraint {
+		return nil
+	}
+	pkg.Constraint = constraint
+	if err := state.Save(); err != nil {
+		return fmt.Errorf("saving state: %w", err)
+	}
+	if constraint == "" {
+		ui.Infof("Updates follow the latest release")
+	} else {
+		ui.Infof("Updates limited to %s", ui.Bold(constraint))
+	}
+	return nil
+}
+
+// splitSpec splits "app-id@version" into its parts, where the version may
+// also be a constraint. It is empty when none is given. App IDs cannot
+// contain '@', so the first one separates.
+func splitSpec(spec string) (appID, ver string) {
+	appID, ver, _ = strings.Cut(spec, "@")
+	return appID, ver
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import "testing"
+
+func TestSplitSpec(t *testing.T) {
+	tests := []struct {
+		spec, appID, ver string
+	}{
+		{"com.example.tool", "com.example.tool", ""},
+		{"com.example.tool@1.4.2", "com.example.tool", "1.4.2"},
+		{"com.example.tool@v2.0.0-rc.1", "com.example.tool", "v2.0.0-rc.1"},
+		{"com.example.tool@", "com.example.tool", ""},
+	}
+	for _, tt := range tests {
+		appID, ver := splitSpec(tt.spec)
+		if appID != tt.appID || ver != tt.ver {
+			t.Errorf("splitSpec(%q) = %q, %q; want %q, %q", tt.spec, appID, ver, tt.appID, tt.ver)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/zapstore/zapstore/install"
+	"github.com/zapstore/zapstore/nostr"
+	"github.com/zapstore/zapstore/store"
+	"github.com/zapstore/zapstore/validate"
+)
+
+// NotInstalledError is returned by commands that act on an installed
+// package when appID is not installed.
+type NotInstalledError struct {
+	AppID string
+}
+
+func (e *NotInstalledError) Error() string {
+	return fmt.Sprintf("package %q is not installed", e.AppID)
+}
+
+// JSONError is the JSON form of an error, with a stable code scripts can
+// match on instead of the message.
+type JSONError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewJSONError describes err for JSON output.
+func NewJSONError(err error) *JSONError {
+	return &JSONError{Code: ErrorCode(err), Message: err.Error()}
+}
+
+// ErrorCode classifies err for JSON output. See README.md for the list.
+func ErrorCode(err error) string {
+	var (
+		notInstalled *NotInstalledError
+		updates      *UpdatesAvailableError
+		locked       *store.LockedError
+		keyChanged   *store.KeyChangedError
+		appNotFound  *nostr.AppNotFoundError
+		relNotFound  *nostr.ReleaseNotFoundError
+		chain        *nostr.ChainError
+	)
+	switch {
+	case errors.As(err, &notInstalled):
+		return "not_installed"
+	case errors.As(err, &updates):
+		return "updates_available"
+	case errors.As(err, &locked):
+		return "locked"
+	case errors.As(err, &keyChanged):
+		return "key_changed"
+	case errors.As(err, &appNotFound):
+		return "app_not_found"
+	case errors.As(err, &relNotFound):
+		return "release_not_found"
+	case errors.As(err, &chain), errors.Is(err, nostr.ErrInvalidEvent):
+		return "untrusted_metadata"
+	case errors.Is(err, validate.ErrUnsafe):
+		return "unsafe_name"
+	case errors.Is(err, install.ErrHashMismatch):
+		return "hash_mismatch"
+	case errors.Is(err, install.ErrNotCached):
+		return "not_cached"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	return "error"
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/zapstore/zapstore/install"
+	"github.com/zapstore/zapstore/nostr"
+	"github.com/zapstore/zapstore/store"
+	"github.com/zapstore/zapstore/validate"
+)
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&NotInstalledError{AppID: "a"}, "not_installed"},
+		{fmt.Errorf("resolving: %w", &nostr.AppNotFoundError{AppID: "a"}), "app_not_found"},
+		{&nostr.ReleaseNotFoundError{AppID: "a"}, "release_not_found"},
+		{&store.KeyChangedError{AppID: "a"}, "key_changed"},
+		{&store.LockedError{PID: 1}, "locked"},
+		{&nostr.ChainError{AppID: "a"}, "untrusted_metadata"},
+		{validate.AppID("../x"), "unsafe_name"},
+		{fmt.Errorf("downloading: %w", install.ErrHashMismatch), "hash_mismatch"},
+		{fmt.Errorf("querying: %w", context.DeadlineExceeded), "timeout"},
+		{fmt.Errorf("app v1: %w (offline)", install.ErrNotCached), "not_cached"},
+		{errors.New("disk full"), "error"},
+	}
+	for _, tt := range tests {
+		if got := ErrorCode(tt.err); got != tt.want {
+			t.Errorf("ErrorCode(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
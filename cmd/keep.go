@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zapstore/zapstore/install"
+	"github.com/zapstore/zapstore/store"
+	"github.com/zapstore/zapstore/ui"
+)
+
+// Keep shows or sets how many versions of a package stay on disk for
+// switch and rollback. Lowering it removes the oldest versions right away.
+func Keep(appID, count string) error {
+	state, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	pkg := state.Get(appID)
+	if pkg == nil {
+		return &NotInstalledError{AppID: appID}
+	}
+
+	if count != "" {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid count %q: must be at least 1", count)
+		}
+		pkg.SetKeep(n)
+		if err := state.Save(); err != nil {
+			return fmt.Errorf("saving state: %w", err)
+		}
+		if err := install.Retain(appID, pkg.Versions()); err != nil {
+			return err
+		}
+	}
+
+	ui.Infof("Keeping %d version(s) of %s %s", pkg.KeepCount(), appID,
+		ui.Dim("(on disk: v"+strings.Join(pkg.Versions(), ", v")+")"))
+	return printPackage("package", appID, pkg)
+}
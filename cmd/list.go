@@ -9,6 +9,20 @@ import (
 	"github.com/zapstore/zapstore/ui"
 )
 
+// packageJSON is the JSON form of an installed package in list.
+type packageJSON struct {
+	AppID       string   `json:"app_id"`
+	Version     string   `json:"version"`
+	Pubkey      string   `json:"pubkey"`
+	InstalledAt string   `json:"installed_at"`
+	Executables []string `json:"executables"`
+	Constraint  string   `json:"constraint"`
+	Held        bool     `json:"held"`
+	Channel     string   `json:"channel"`
+	Versions    []string `json:"versions"` // on disk, active first
+	Keep        int      `json:"keep"`
+}
+
 // List prints all installed packages.
 func List() error {
 	state, err := store.Load()
@@ -16,20 +30,19 @@ func List() error {
 		return fmt.Errorf("loading state: %w", err)
 	}
 
+	if ui.JSON {
+		return listJSON(state)
+	}
+
 	if len(state.Packages) == 0 {
 		ui.Infof("No packages installed.")
 		return nil
 	}
 
-	// Sort by app ID
-	ids := make([]string, 0, len(state.Packages))
-	for id := range state.Packages {
-		ids = append(ids, id)
-	}
-	sort.Strings(ids)
+	ids := sortedIDs(state)
 
 	// Calculate column widths
-	maxID, maxVer, maxExe := len("PACKAGE"), len("VERSION"), len("EXECUTABLES")
+	maxID, maxVer, maxPin, maxExe := len("PACKAGE"), len("VERSION"), len("PINNED"), len("EXECUTABLES")
 	for _, id := range ids {
 		pkg := state.Packages[id]
 		if len(id) > maxID {
@@ -38,6 +51,9 @@ func List() error {
 		if len(pkg.Version) > maxVer {
 			maxVer = len(pkg.Version)
 		}
+		if len(pinLabel(pkg)) > maxPin {
+			maxPin = len(pinLabel(pkg))
+		}
 		exe := strings.Join(pkg.Executables, ", ")
 		if len(exe) > maxExe {
 			maxExe = len(exe)
@@ -46,8 +62,8 @@ func List() error {
 
 	fmt.Println()
 	ui.TableHeader(
-		[]int{maxID, maxVer, maxExe, 19},
-		"PACKAGE", "VERSION", "EXECUTABLES", "INSTALLED",
+		[]int{maxID, maxVer, maxPin, maxExe, 19},
+		"PACKAGE", "VERSION", "PINNED", "EXECUTABLES", "INSTALLED",
 	)
 
 	for _, id := range ids {
@@ -57,9 +73,10 @@ func List() error {
 		if len(installed) > 19 {
 			installed = installed[:19]
 		}
-		fmt.Printf("%-*s  %-*s  %-*s  %s\n",
+		fmt.Printf("%-*s  %-*s  %-*s  %-*s  %s\n",
 			maxID, id,
 			maxVer, ui.Bold(pkg.Version),
+			maxPin, pinLabel(pkg),
 			maxExe, exe,
 			ui.Dim(installed),
 		)
@@ -68,3 +85,72 @@ func List() error {
 	fmt.Printf("\n%s\n", ui.Dim(fmt.Sprintf("%d package(s) installed.", len(ids))))
 	return nil
 }
+
+func listJSON(state *store.State) error {
+	config, err := store.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	packages := []packageJSON{}
+	for _, id := range sortedIDs(state) {
+		packages = append(packages, newPackageJSON(id, state.Packages[id], config))
+	}
+	return ui.PrintJSON(map[string]any{"packages": packages})
+}
+
+func newPackageJSON(id string, pkg *store.Package, config *store.Config) packageJSON {
+	return packageJSON{
+		AppID:       id,
+		Version:     pkg.Version,
+		Pubkey:      pkg.Pubkey,
+		InstalledAt: pkg.InstalledAt,
+		Executables: nonNil(pkg.Executables),
+		Constraint:  pkg.Constraint,
+		Held:        isHeld(pkg),
+		Channel:     config.ChannelFor(pkg),
+		Versions:    pkg.Versions(),
+		Keep:        pkg.KeepCount(),
+	}
+}
+
+// printPackage prints the JSON document of a command that acts on one
+// package: {key: package}, as in list. It prints nothing outside JSON mode.
+func printPackage(key, id string, pkg *store.Package) error {
+	if !ui.JSON {
+		return nil
+	}
+	config, err := store.LoadConfig()
+	if err != nil {
+		return err
+	}
+	return ui.PrintJSON(map[string]any{key: newPackageJSON(id, pkg, config)})
+}
+
+// sortedIDs returns the IDs of all installed packages in order.
+func sortedIDs(state *store.State) []string {
+	ids := make([]string, 0, len(state.Packages))
+	for id := range state.Packages {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// nonNil returns s, or an empty slice if it is nil, so JSON output has []
+// rather than null.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// pinLabel describes a package's pin for the PINNED column: "held" for an
+// exact pin, the constraint for a range, and "" if unpinned.
+func pinLabel(pkg *store.Package) string {
+	if isHeld(pkg) {
+		return "held"
+	}
+	return pkg.Constraint
+}
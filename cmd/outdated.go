@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zapstore/zapstore/nostr"
+	"github.com/zapstore/zapstore/platform"
+	"github.com/zapstore/zapstore/store"
+	"github.com/zapstore/zapstore/ui"
+	"github.com/zapstore/zapstore/version"
+)
+
+// UpdatesAvailableError is returned by Outdated and a dry-run Update when at
+// least one package can be updated, so scripts can fail on stale packages.
+type UpdatesAvailableError struct {
+	Count int
+}
+
+func (e *UpdatesAvailableError) Error() string {
+	return fmt.Sprintf("%d package(s) can be updated", e.Count)
+}
+
+// Package statuses in the JSON output of update and outdated.
+const (
+	statusUpdated   = "updated"    // update installed a newer release
+	statusAvailable = "available"  // a newer release would be installed
+	statusUpToDate  = "up_to_date" // nothing newer within the pin and channel
+	statusHeld      = "held"       // pinned to one version, not checked
+	statusFailed    = "failed"     // see error
+	statusSkipped   = "skipped"    // not installed after saving state failed
+)
+
+// updateJSON is the JSON form of one package's outcome in update and
+// outdated.
+type updateJSON struct {
+	AppID      string     `json:"app_id"`
+	Version    string     `json:"version"`   // installed when the command started
+	Available  string     `json:"available"` // release updated to or available, "" if none
+	Constraint string     `json:"constraint"`
+	Status     string     `json:"status"`
+	Error      *JSONError `json:"error,omitempty"`
+}
+
+func (u updateJSON) with(status, available string) updateJSON {
+	u.Status, u.Available = status, available
+	return u
+}
+
+func (u updateJSON) failed(err error) updateJSON {
+	u.Status, u.Error = statusFailed, NewJSONError(err)
+	return u
+}
+
+// printUpdates prints the JSON document of update and outdated. It prints
+// nothing outside JSON mode.
+func printUpdates(results []updateJSON) error {
+	if !ui.JSON {
+		return nil
+	}
+	if results == nil {
+		results = []updateJSON{}
+	}
+	return ui.PrintJSON(map[string]any{"packages": results})
+}
+
+// pendingUpdate is a release update would install for a package.
+type pendingUpdate struct {
+	app     *nostr.AppInfo
+	release *nostr.ReleaseInfo
+	asset   *nostr.AssetInfo
+}
+
+// resolveUpdates resolves, in one batch, the release update would consider
+// for each of ids, within its pin and channel and from its trusted
+// publisher where there is one. Packages held at one version are left out.
+func resolveUpdates(ctx context.Context, client *nostr.Client, state *store.State, trust *store.Trust, ids []string, config *store.Config, plat platform.Info) map[string]*nostr.Resolution {
+	var reqs []nostr.ResolveRequest
+	for _, id := range ids {
+		pkg := state.Get(id)
+		if isHeld(pkg) {
+			continue
+		}
+		reqs = append(reqs, nostr.ResolveRequest{
+			AppID:      id,
+			Constraint: pkg.Constraint,
+			Channel:    config.ChannelFor(pkg),
+			Publisher:  trust.Publisher(id),
+		})
+	}
+	return client.ResolveAll(ctx, reqs, plat)
+}
+
+// findUpdate returns the update for pkg from its resolution, if it is newer
+// and from the trusted publisher. Returns nil if pkg is up to date.
+func findUpdate(id string, pkg *store.Package, res *nostr.Resolution, trust *store.Trust) (*pendingUpdate, error) {
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	if err := trust.Check(id, res.App.Pubkey); err != nil {
+		return nil, err
+	}
+	if !version.CanUpgrade(pkg.Version, res.Release.Version) {
+		return nil, nil
+	}
+	return &pendingUpdate{app: res.App, release: res.Release, asset: res.Asset}, nil
+}
+
+// Outdated lists installed packages that update would move to a newer
+// release. Returns an *UpdatesAvailableError if there are any.
+func Outdated(client *nostr.Client) error {
+	state, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	if len(state.Packages) == 0 {
+		ui.Infof("No packages installed.")
+		return printUpdates(nil)
+	}
+
+	trust, err := store.LoadTrust()
+	if err != nil {
+		return err
+	}
+	trust.Seed(state)
+
+	config, err := store.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	ids := sortedIDs(state)
+
+	plat := platform.Detect()
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	sp := ui.NewSpinner(fmt.Sprintf("Checking %d package(s)...", len(ids)))
+	sp.Start()
+	resolved := resolveUpdates(ctx, client, state, trust, ids, config, plat)
+	sp.Stop()
+
+	var results []updateJSON
+	updates := make(map[string]*pendingUpdate)
+	failed := make(map[string]error)
+	for _, id := range ids {
+		pkg := state.Get(id)
+		res := updateJSON{AppID: id, Version: pkg.Version, Constraint: pkg.Constraint}
+		if isHeld(pkg) {
+			results = append(results, res.with(statusHeld, ""))
+			continue
+		}
+		up, err := findUpdate(id, pkg, resolved[id], trust)
+		switch {
+		case err != nil:
+			failed[id] = err
+			results = append(results, res.failed(err))
+		case up != nil:
+			updates[id] = up
+			results = append(results, res.with(statusAvailable, up.release.Version))
+		default:
+			results = append(results, res.with(statusUpToDate, ""))
+		}
+	}
+
+	if err := printUpdates(results); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err, ok := failed[id]; ok {
+			ui.Errorf("%s: %v", id, err)
+		}
+	}
+
+	if len(updates) > 0 && !ui.JSON {
+		maxID, maxCur, maxAvail, maxPin := len("PACKAGE"), len("INSTALLED"), len("AVAILABLE"), len("PINNED")
+		for id, up := range updates {
+			pkg := state.Get(id)
+			maxID = max(maxID, len(id))
+			maxCur = max(maxCur, len(pkg.Version))
+			maxAvail = max(maxAvail, len(up.release.Version))
+			maxPin = max(maxPin, len(pinLabel(pkg)))
+		}
+
+		fmt.Println()
+		ui.TableHeader(
+			[]int{maxID, maxCur, maxAvail, maxPin},
+			"PACKAGE", "INSTALLED", "AVAILABLE", "PINNED",
+		)
+		for _, id := range ids {
+			up, ok := updates[id]
+			if !ok {
+				continue
+			}
+			pkg := state.Get(id)
+			fmt.Printf("%-*s  %-*s  %s  %s\n",
+				maxID, id,
+				maxCur, pkg.Version,
+				ui.Bold(fmt.Sprintf("%-*s", maxAvail, up.release.Version)),
+				pinLabel(pkg),
+			)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("could not check %d package(s)", len(failed))
+	}
+	if len(updates) > 0 {
+		return &UpdatesAvailableError{Count: len(updates)}
+	}
+	if !ui.JSON {
+		fmt.Println()
+	}
+	ui.Successf("All packages are up to date.")
+	return nil
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/zapstore/zapstore/store"
+	"github.com/zapstore/zapstore/ui"
+	"github.com/zapstore/zapstore/version"
+)
+
+// Pin holds an installed package so update leaves it alone. Without a
+// constraint it is held at the installed version; with one, such as ^1.2,
+// update only moves it within that range. An exact version must be the
+// installed one, since a hold never moves the package.
+func Pin(appID, constraint string) error {
+	state, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	pkg := state.Get(appID)
+	if pkg == nil {
+		return &NotInstalledError{AppID: appID}
+	}
+
+	// Without a constraint, hold the installed version as written: it need
+	// not parse as a constraint (r123)
+	outside := false
+	if constraint == "" {
+		constraint = pkg.Version
+	} else {
+		c, err := version.ParseSpec(constraint)
+		if err != nil {
+			return err
+		}
+		outside = !c.Check(pkg.Version)
+		if c.IsExact() && outside {
+			return fmt.Errorf("cannot hold %s at %s while v%s is installed; run 'zapstore install %s@%s' first",
+				appID, c, pkg.Version, appID, c)
+		}
+		constraint = c.String()
+	}
+
+	pkg.Constraint = constraint
+	if err := state.Save(); err != nil {
+		return fmt.Errorf("saving state: %w", err)
+	}
+
+	if isHeld(pkg) {
+		ui.Successf("Pinned %s at %s", appID, ui.Bold("v"+pkg.Version))
+	} else {
+		ui.Successf("Pinned %s to %s", appID, ui.Bold(constraint))
+	}
+	if outside {
+		ui.Warningf("Installed v%s is outside %s; run 'zapstore install %s@%s' to move into it",
+			pkg.Version, constraint, appID, constraint)
+	}
+	return printPackage("package", appID, pkg)
+}
+
+// Unpin lets update move an installed package to the latest release again.
+func Unpin(appID string) error {
+	state, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	pkg := state.Get(appID)
+	if pkg == nil {
+		return &NotInstalledError{AppID: appID}
+	}
+	if pkg.Constraint == "" {
+		ui.Infof("%s is not pinned", appID)
+		return printPackage("package", appID, pkg)
+	}
+
+	pkg.Constraint = ""
+	if err := state.Save(); err != nil {
+		return fmt.Errorf("saving state: %w", err)
+	}
+
+	ui.Successf("Unpinned %s %s", appID, ui.Dim("(updates follow the latest release)"))
+	return printPackage("package", appID, pkg)
+}
+
+// isHeld reports whether a package is pinned to exactly one version, so
+// update need not look for releases at all. A constraint equal to the
+// installed version as written is a hold even if it does not parse.
+func isHeld(pkg *store.Package) bool {
+	switch pkg.Constraint {
+	case "":
+		return false
+	case pkg.Version:
+		return true
+	}
+	c, err := version.ParseSpec(pkg.Constraint)
+	return err == nil && c.IsExact()
+}
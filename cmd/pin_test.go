@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/zapstore/zapstore/nostr"
+	"github.com/zapstore/zapstore/platform"
+	"github.com/zapstore/zapstore/store"
+)
+
+func TestPinLabel(t *testing.T) {
+	tests := []struct {
+		constraint string
+		want       string
+		held       bool
+	}{
+		{"", "", false},
+		{"1.4.2", "held", true},
+		{"=1.4.2", "held", true},
+		{"^1.2", "^1.2", false},
+		{">=1.0 <2.0", ">=1.0 <2.0", false},
+	}
+	for _, tt := range tests {
+		pkg := &store.Package{Version: "1.4.2", Constraint: tt.constraint}
+		if got := pinLabel(pkg); got != tt.want {
+			t.Errorf("pinLabel(%q) = %q, want %q", tt.constraint, got, tt.want)
+		}
+		if got := isHeld(pkg); got != tt.held {
+			t.Errorf("isHeld(%q) = %v, want %v", tt.constraint, got, tt.held)
+		}
+	}
+}
+
+// captureStdout returns what fn prints to stdout.
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+
+	done := make(chan []byte)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- out
+	}()
+	ferr := fn()
+	w.Close()
+	return string(<-done), ferr
+}
+
+func TestPin(t *testing.T) {
+	for _, env := range []string{"XDG_STATE_HOME", "XDG_DATA_HOME", "XDG_CONFIG_HOME", "XDG_CACHE_HOME"} {
+		t.Setenv(env, t.TempDir())
+	}
+	state := &store.State{Packages: map[string]*store.Package{}}
+	state.Add("com.example.tool", &store.Package{Version: "1.4.2"})
+	state.Add("com.example.nightly", &store.Package{Version: "r123"})
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		appID, constraint string
+		stored            string // "" when the pin must fail and leave none
+		out               []string
+	}{
+		{"com.example.tool", "", "1.4.2", []string{"Pinned com.example.tool at", "v1.4.2"}},
+		{"com.example.tool", "^1.2", "^1.2", []string{"Pinned com.example.tool to", "^1.2"}},
+		{"com.example.tool", "^2", "^2", []string{"Pinned com.example.tool to", "Installed v1.4.2 is outside ^2"}},
+		{"com.example.tool", "=1.4.2", "=1.4.2", []string{"at", "v1.4.2"}},
+		{"com.example.tool", "1.5.0", "", nil}, // would hold a version that is not installed
+		{"com.example.nightly", "", "r123", []string{"Pinned com.example.nightly at", "vr123"}},
+		{"com.example.nightly", "r123", "r123", []string{"at", "vr123"}},
+		{"com.example.nightly", "r124", "", nil},
+	}
+	for _, tt := range tests {
+		if err := Unpin(tt.appID); err != nil {
+			t.Fatal(err)
+		}
+		out, err := captureStdout(t, func() error { return Pin(tt.appID, tt.constraint) })
+
+		state, lerr := store.Load()
+		if lerr != nil {
+			t.Fatal(lerr)
+		}
+		got := state.Get(tt.appID).Constraint
+		if got != tt.stored {
+			t.Errorf("Pin(%s, %q) stored %q, want %q", tt.appID, tt.constraint, got, tt.stored)
+		}
+		if tt.stored == "" {
+			if err == nil {
+				t.Errorf("Pin(%s, %q) succeeded, want an error", tt.appID, tt.constraint)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Pin(%s, %q): %v", tt.appID, tt.constraint, err)
+		}
+		for _, want := range tt.out {
+			if !strings.Contains(out, want) {
+				t.Errorf("Pin(%s, %q) printed %q, want it to contain %q", tt.appID, tt.constraint, out, want)
+			}
+		}
+	}
+
+	// update leaves held packages out of its resolution
+	if err := Pin("com.example.nightly", ""); err != nil {
+		t.Fatal(err)
+	}
+	state, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := sortedIDs(state)
+	resolved := resolveUpdates(context.Background(), nostr.NewClient(nil), state, &store.Trust{Pins: map[string]*store.Pin{}},
+		ids, &store.Config{}, platform.Detect())
+	if _, ok := resolved["com.example.nightly"]; ok {
+		t.Error("update resolved the held com.example.nightly")
+	}
+	if _, ok := resolved["com.example.tool"]; !ok {
+		t.Error("update skipped com.example.tool, pinned only to a range")
+	}
+
+	out, err := captureStdout(t, func() error { return Unpin("com.example.nightly") })
+	if err != nil || !strings.Contains(out, "Unpinned com.example.nightly") {
+		t.Errorf("Unpin printed %q, %v", out, err)
+	}
+	if state, _ := store.Load(); state.Get("com.example.nightly").Constraint != "" {
+		t.Error("Unpin left a constraint behind")
+	}
+}
@@ -17,13 +17,13 @@ func Remove(appID string) error {
 
 	pkg := state.Get(appID)
 	if pkg == nil {
-		return fmt.Errorf("package %q is not installed", appID)
+		return &NotInstalledError{AppID: appID}
 	}
 
 	sp := ui.NewSpinner(fmt.Sprintf("Removing %s v%s...", appID, pkg.Version))
 	sp.Start()
 
-	if err := install.Uninstall(appID, pkg.Executables); err != nil {
+	if err := install.Uninstall(appID, pkg.Executables, pkg.Extras); err != nil {
 		sp.StopWithError(fmt.Sprintf("Failed to remove %s", appID))
 		return fmt.Errorf("uninstalling: %w", err)
 	}
@@ -35,5 +35,5 @@ func Remove(appID string) error {
 	}
 
 	sp.StopWithSuccess(fmt.Sprintf("Removed %s %s", appID, ui.Dim("v"+pkg.Version)))
-	return nil
+	return printPackage("removed", appID, pkg)
 }
@@ -7,25 +7,60 @@ import (
 
 	"github.com/zapstore/zapstore/nostr"
 	"github.com/zapstore/zapstore/platform"
+	"github.com/zapstore/zapstore/store"
 	"github.com/zapstore/zapstore/ui"
 )
 
-// Search queries the relay for apps matching the query and prints results.
-func Search(query string) error {
+// searchHitJSON is the JSON form of a search result.
+type searchHitJSON struct {
+	AppID   string `json:"app_id"`
+	Name    string `json:"name"`
+	Summary string `json:"summary"`
+	Pubkey  string `json:"pubkey"`
+	Version string `json:"version"` // latest on the global channel, "" if none
+}
+
+// Search queries the relays for apps matching the query and prints results
+// with their latest release on the global channel.
+func Search(client *nostr.Client, query string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	config, err := store.LoadConfig()
+	if err != nil {
+		return err
+	}
+
 	plat := platform.Detect()
 
 	sp := ui.NewSpinner(fmt.Sprintf("Searching for %q...", query))
 	sp.Start()
 
-	apps, err := nostr.SearchApps(ctx, nostr.RelayURL(), query, plat)
+	apps, err := client.SearchApps(ctx, query, plat)
 	if err != nil {
 		sp.StopWithError("Search failed")
 		return err
 	}
 
+	// Versions are only decoration; search still works without them
+	latest, err := client.LatestReleases(ctx, apps, config.ChannelFor(nil))
+	if err != nil {
+		nostr.Warnf("looking up versions: %v", err)
+	}
+
+	if ui.JSON {
+		sp.Stop()
+		hits := []searchHitJSON{}
+		for _, app := range apps {
+			hit := searchHitJSON{AppID: app.AppID, Name: app.Name, Summary: app.Summary, Pubkey: app.Pubkey}
+			if r := latest[app.AppID]; r != nil {
+				hit.Version = r.Version
+			}
+			hits = append(hits, hit)
+		}
+		return ui.PrintJSON(map[string]any{"query": query, "results": hits})
+	}
+
 	if len(apps) == 0 {
 		sp.StopWithWarning("No results found.")
 		return nil
@@ -35,7 +70,11 @@ func Search(query string) error {
 	fmt.Println()
 
 	for _, app := range apps {
-		fmt.Printf("  %s %s\n", ui.Bold(app.AppID), "")
+		ver := ""
+		if r := latest[app.AppID]; r != nil {
+			ver = ui.Dim("v" + r.Version)
+		}
+		fmt.Printf("  %s %s\n", ui.Bold(app.AppID), ver)
 		if app.Summary != "" {
 			fmt.Printf("    %s\n", ui.Dim(app.Summary))
 		}
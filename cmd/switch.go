@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zapstore/zapstore/install"
+	"github.com/zapstore/zapstore/store"
+	"github.com/zapstore/zapstore/ui"
+	"github.com/zapstore/zapstore/version"
+)
+
+// Switch makes a version of an installed package that is still on disk the
+// active one. It needs no network access.
+func Switch(appID, ver string) error {
+	state, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	pkg := state.Get(appID)
+	if pkg == nil {
+		return &NotInstalledError{AppID: appID}
+	}
+	active, target := findVersion(pkg, ver)
+	if active {
+		ui.Infof("%s v%s is already active", appID, pkg.Version)
+		return printPackage("package", appID, pkg)
+	}
+	if target == nil {
+		return fmt.Errorf("%s v%s is not on disk; available: %s", appID, ver, strings.Join(pkg.Versions(), ", "))
+	}
+
+	return activate(state, appID, pkg, target)
+}
+
+// findVersion looks ver up among the versions of pkg on disk: active
+// reports that it is the active one, and target is the kept version it
+// names otherwise, nil if there is none. A version written exactly as ver
+// wins over an equivalent one (v1.2 for 1.2.0), and versions that are not
+// numeric only match exactly (see version.Equal).
+func findVersion(pkg *store.Package, ver string) (active bool, target *store.Package) {
+	if pkg.Version == ver {
+		return true, nil
+	}
+	for _, prev := range pkg.Previous {
+		if prev.Version == ver {
+			return false, prev
+		}
+	}
+	if version.Equal(pkg.Version, ver) {
+		return true, nil
+	}
+	for _, prev := range pkg.Previous {
+		if version.Equal(prev.Version, ver) {
+			return false, prev
+		}
+	}
+	return false, nil
+}
+
+// Rollback returns an installed package to the version that was active
+// before the current one. It needs no network access.
+func Rollback(appID string) error {
+	state, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	pkg := state.Get(appID)
+	if pkg == nil {
+		return &NotInstalledError{AppID: appID}
+	}
+	if len(pkg.Previous) == 0 {
+		return fmt.Errorf("no previous version of %q on disk to roll back to", appID)
+	}
+
+	return activate(state, appID, pkg, pkg.Previous[0])
+}
+
+// activate relinks a kept version and records it as active, undoing the
+// links again if state cannot be saved.
+func activate(state *store.State, appID string, from, to *store.Package) error {
+	sp := ui.NewSpinner(fmt.Sprintf("Switching %s to v%s...", appID, to.Version))
+	sp.Start()
+
+	result, err := install.Switch(appID, from, to)
+	if err != nil {
+		sp.StopWithError(fmt.Sprintf("Failed to switch %s", appID))
+		return err
+	}
+
+	// A pin on the exact version follows the switch
+	to.Constraint = from.Constraint
+	if isHeld(from) {
+		to.Constraint = to.Version
+	}
+	state.Add(appID, to)
+	if err := state.Save(); err != nil {
+		sp.StopWithError("Failed to save state")
+		if rerr := result.Rollback(); rerr != nil {
+			ui.Errorf("rollback: %v", rerr)
+		}
+		return fmt.Errorf("saving state: %w", err)
+	}
+	result.Commit(state.Get(appID).Versions())
+
+	sp.StopWithSuccess(fmt.Sprintf("Switched %s %s %s %s", appID, ui.Dim("v"+from.Version), ui.Arrow(), ui.Bold("v"+to.Version)))
+	return printPackage("package", appID, state.Get(appID))
+}
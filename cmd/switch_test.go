@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/zapstore/zapstore/store"
+)
+
+func TestFindVersion(t *testing.T) {
+	tests := []struct {
+		active string
+		kept   []string
+		ver    string
+		want   string // "active", the kept version found, or "" for none
+	}{
+		{"2.0.0", []string{"1.0.0"}, "1.0.0", "1.0.0"},
+		{"2.0.0", []string{"1.0.0"}, "v1.0", "1.0.0"},
+		{"2.0.0", []string{"1.0.0"}, "v2", "active"},
+		{"2.0.0", []string{"1.0.0"}, "3.0", ""},
+		// An exact spelling beats an equivalent one
+		{"2.0.0", []string{"1.0", "1.0.0"}, "1.0.0", "1.0.0"},
+		// Tags Compare reads as 0 only match as written
+		{"r124", []string{"r123"}, "r123", "r123"},
+		{"r124", []string{"r123"}, "r999", ""},
+		{"r124", []string{"r123"}, "r124", "active"},
+		{"2.0", []string{"1.0~beta"}, "1.0", ""},
+		{"2.0", []string{"1.0"}, "1.0~beta", ""},
+		{"2.0", []string{"1.0~beta"}, "1.0~beta", "1.0~beta"},
+		{"1.0~beta", nil, "1.0", ""},
+	}
+	for _, tt := range tests {
+		pkg := &store.Package{Version: tt.active}
+		for _, v := range tt.kept {
+			pkg.Previous = append(pkg.Previous, &store.Package{Version: v})
+		}
+		active, target := findVersion(pkg, tt.ver)
+		got := ""
+		switch {
+		case active:
+			got = "active"
+		case target != nil:
+			got = target.Version
+		}
+		if got != tt.want {
+			t.Errorf("findVersion(%s %v, %q) = %q, want %q", tt.active, tt.kept, tt.ver, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zapstore/zapstore/nostr"
+	"github.com/zapstore/zapstore/platform"
+	"github.com/zapstore/zapstore/store"
+	"github.com/zapstore/zapstore/ui"
+)
+
+// Trust re-pins an app to the publisher key currently found on the relays,
+// showing the previously trusted key alongside the new one.
+func Trust(client *nostr.Client, appID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	state, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	trust, err := store.LoadTrust()
+	if err != nil {
+		return err
+	}
+	trust.Seed(state)
+
+	sp := ui.NewSpinner(fmt.Sprintf("Resolving %s...", appID))
+	sp.Start()
+
+	// Take the newest app event whoever signed it: that is the key to review
+	app, err := client.ResolveApp(ctx, appID, "", platform.Detect())
+	if err != nil {
+		sp.StopWithError(fmt.Sprintf("Failed to resolve %s", appID))
+		return err
+	}
+	sp.StopWithSuccess(fmt.Sprintf("Found %s", ui.Bold(app.Name)))
+
+	out := trustJSON{AppID: appID, Pubkey: app.Pubkey, Npub: store.Npub(app.Pubkey)}
+
+	pin := trust.Get(appID)
+	switch {
+	case pin == nil:
+		ui.Infof("No key pinned yet")
+	case pin.Pubkey == app.Pubkey:
+		ui.Infof("Already trusted %s", ui.Dim(store.Npub(app.Pubkey)))
+		out.Previous = pin.Pubkey
+		return printTrust(out)
+	default:
+		ui.Warningf("Publisher key changed")
+		if !ui.JSON {
+			fmt.Printf("    %s %s\n", ui.Dim("old"), store.Npub(pin.Pubkey))
+			fmt.Printf("    %s %s\n", ui.Dim("new"), store.Npub(app.Pubkey))
+		}
+		out.Previous = pin.Pubkey
+	}
+
+	trust.Pin(appID, app.Pubkey)
+	if err := trust.Save(); err != nil {
+		return fmt.Errorf("saving trust database: %w", err)
+	}
+
+	ui.Resultf("Trusted %s for %s", store.Npub(app.Pubkey), appID)
+	return printTrust(out)
+}
+
+// trustJSON is the JSON form of trust's outcome.
+type trustJSON struct {
+	AppID    string `json:"app_id"`
+	Pubkey   string `json:"pubkey"` // hex, now trusted
+	Npub     string `json:"npub"`
+	Previous string `json:"previous_pubkey"` // hex, "" if none was pinned
+}
+
+func printTrust(out trustJSON) error {
+	if !ui.JSON {
+		return nil
+	}
+	return ui.PrintJSON(out)
+}
@@ -3,7 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"sort"
+	"sync"
 	"time"
 
 	"github.com/zapstore/zapstore/install"
@@ -11,12 +11,16 @@ import (
 	"github.com/zapstore/zapstore/platform"
 	"github.com/zapstore/zapstore/store"
 	"github.com/zapstore/zapstore/ui"
-	"github.com/zapstore/zapstore/version"
 )
 
+// DefaultJobs is the number of downloads update runs at once by default.
+const DefaultJobs = 4
+
 // Update checks for and applies updates. If appID is empty, updates all
-// installed packages.
-func Update(appID string) error {
+// installed packages. With dryRun it only reports what would be updated,
+// returning an *UpdatesAvailableError if anything would be. Up to jobs
+// downloads run at once; installs happen one at a time as each finishes.
+func Update(client *nostr.Client, appID string, dryRun bool, jobs int) error {
 	state, err := store.Load()
 	if err != nil {
 		return fmt.Errorf("loading state: %w", err)
@@ -24,81 +28,298 @@ func Update(appID string) error {
 
 	if len(state.Packages) == 0 {
 		ui.Infof("No packages installed.")
-		return nil
+		return printUpdates(nil)
+	}
+
+	trust, err := store.LoadTrust()
+	if err != nil {
+		return err
+	}
+	trust.Seed(state)
+
+	config, err := store.LoadConfig()
+	if err != nil {
+		return err
 	}
 
 	// Determine which packages to update
 	var targets []string
 	if appID != "" {
 		if state.Get(appID) == nil {
-			return fmt.Errorf("package %q is not installed", appID)
+			return &NotInstalledError{AppID: appID}
 		}
 		targets = []string{appID}
 	} else {
-		for id := range state.Packages {
-			targets = append(targets, id)
-		}
-		sort.Strings(targets)
+		targets = sortedIDs(state)
 	}
 
 	plat := platform.Detect()
 	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
 	defer cancel()
 
-	updated := 0
-	for _, id := range targets {
+	sp := ui.NewSpinner(fmt.Sprintf("Checking %d package(s)...", len(targets)))
+	sp.Start()
+	resolved := resolveUpdates(ctx, client, state, trust, targets, config, plat)
+	sp.Stop()
+
+	results := make([]updateJSON, len(targets))
+	var queue []*queuedUpdate
+	updated, failed := 0, 0
+	for i, id := range targets {
 		pkg := state.Get(id)
+		res := updateJSON{AppID: id, Version: pkg.Version, Constraint: pkg.Constraint}
 
-		sp := ui.NewSpinner(fmt.Sprintf("Checking %s %s...", id, ui.Dim("v"+pkg.Version)))
-		sp.Start()
+		// Pinned to one version: nothing to look up
+		if isHeld(pkg) {
+			ui.Infof("%s %s", id, ui.Dim("held at v"+pkg.Version))
+			results[i] = res.with(statusHeld, "")
+			continue
+		}
 
-		app, release, asset, err := nostr.Resolve(ctx, nostr.RelayURL(), id, plat)
+		up, err := findUpdate(id, pkg, resolved[id], trust)
 		if err != nil {
-			sp.StopWithError(fmt.Sprintf("%s: %v", id, err))
+			ui.Errorf("%s: %v", id, err)
+			results[i] = res.failed(err)
+			failed++
 			continue
 		}
 
-		if !version.CanUpgrade(pkg.Version, release.Version) {
-			sp.StopWithSuccess(fmt.Sprintf("%s %s", id, ui.Dim("up to date")))
+		if up == nil {
+			status := "up to date"
+			if pkg.Constraint != "" {
+				status = fmt.Sprintf("held at v%s (%s)", pkg.Version, pkg.Constraint)
+			}
+			ui.Successf("%s %s", id, ui.Dim(status))
+			results[i] = res.with(statusUpToDate, "")
 			continue
 		}
 
-		sp.StopWithSuccess(fmt.Sprintf("%s %s %s %s", id, ui.Dim("v"+pkg.Version), ui.Arrow(), ui.Bold("v"+release.Version)))
+		if dryRun {
+			ui.Successf("%s %s %s %s", id, ui.Dim("v"+pkg.Version), ui.Arrow(), ui.Bold("v"+up.release.Version))
+			results[i] = res.with(statusAvailable, up.release.Version)
+			updated++
+			continue
+		}
+		queue = append(queue, &queuedUpdate{pendingUpdate: up, index: i, id: id, pkg: pkg, res: res})
+	}
 
-		result, err := install.Run(install.Options{
-			AppID:    id,
-			Version:  release.Version,
-			URL:      asset.URL,
-			Hash:     asset.Hash,
-			Filename: asset.Filename,
-			Pubkey:   app.Pubkey,
-			EventID:  asset.Event.ID,
-		})
+	if len(queue) > 0 {
+		if !ui.JSON {
+			fmt.Println()
+		}
+		err := applyUpdates(state, queue, jobs)
+		for _, q := range queue {
+			results[q.index] = q.res
+			switch q.res.Status {
+			case statusUpdated:
+				updated++
+			case statusFailed:
+				failed++
+			}
+		}
 		if err != nil {
-			ui.Errorf("%s: %v", id, err)
+			// Still report what was updated before state could not be saved
+			if ui.JSON {
+				printUpdates(results)
+			}
+			return err
+		}
+	}
+
+	if dryRun {
+		if err := printUpdates(results); err != nil {
+			return err
+		}
+		if !ui.JSON {
+			fmt.Println()
+		}
+		if failed > 0 {
+			return fmt.Errorf("could not check %d package(s)", failed)
+		}
+		if updated > 0 {
+			return &UpdatesAvailableError{Count: updated}
+		}
+		ui.Successf("All packages are up to date.")
+		return nil
+	}
+
+	if err := trust.Save(); err != nil {
+		return fmt.Errorf("saving trust database: %w", err)
+	}
+
+	if ui.JSON {
+		if err := printUpdates(results); err != nil {
+			return err
+		}
+	} else {
+		fmt.Println()
+		switch {
+		case updated > 0:
+			ui.Successf("Updated %d package(s).", updated)
+		case failed == 0:
+			ui.Successf("All packages are up to date.")
+		}
+	}
+
+	// A refused key change or a failed install must not pass for success
+	if failed > 0 {
+		return fmt.Errorf("%d package(s) failed", failed)
+	}
+	return nil
+}
+
+// queuedUpdate is an update waiting for its download and install.
+type queuedUpdate struct {
+	*pendingUpdate
+	index int // in the command's results
+	id    string
+	pkg   *store.Package
+	opts  install.Options
+	err   error // from the download
+	res   updateJSON
+}
+
+// applyUpdates downloads the queued updates, up to jobs at a time, and
+// installs each one as soon as its download is done. Installs, link swaps
+// and state saves all happen on the calling goroutine, one at a time, and
+// progress is shown with one row per package. Updates sharing an asset
+// download it once. Each update's outcome is left in its res; an error is
+// returned only if state could not be saved, and the updates not installed
+// by then are left skipped.
+func applyUpdates(state *store.State, queue []*queuedUpdate, jobs int) error {
+	mp := ui.NewMultiProgress()
+	for _, q := range queue {
+		q.opts = install.Options{
+			AppID:       q.id,
+			Version:     q.release.Version,
+			URL:         q.asset.URL,
+			Hash:        q.asset.Hash,
+			Filename:    q.asset.Filename,
+			Size:        q.asset.Size,
+			MIME:        q.asset.MIME,
+			Executables: q.asset.Executables,
+			Pubkey:      q.app.Pubkey,
+			EventID:     q.asset.Event.ID,
+			Offline:     nostr.Offline,
+			Previous:    q.pkg,
+			Progress:    mp.Add(q.id, "Waiting"),
+		}
+	}
+	mp.Start()
+	defer mp.Stop()
+
+	// Only the first update with a given asset downloads it: the others
+	// would write the same partial file. They install after it, from the
+	// cache.
+	var fetch []*queuedUpdate
+	leaders := make(map[string]*queuedUpdate)
+	followers := make(map[*queuedUpdate][]*queuedUpdate)
+	for _, q := range queue {
+		if lead := leaders[q.opts.Hash]; lead != nil {
+			followers[lead] = append(followers[lead], q)
 			continue
 		}
+		if q.opts.Hash != "" {
+			leaders[q.opts.Hash] = q
+		}
+		fetch = append(fetch, q)
+	}
 
-		state.Add(id, &store.Package{
-			Pubkey:       app.Pubkey,
-			Version:      release.Version,
-			Executables:  []string{result.BinaryName},
-			AssetEventID: asset.Event.ID,
-		})
+	// Buffered so workers never wait on the installs, and stop so no new
+	// download starts once saving state has failed
+	work := make(chan *queuedUpdate)
+	fetched := make(chan *queuedUpdate, len(fetch))
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		defer close(work)
+		for _, q := range fetch {
+			select {
+			case work <- q:
+			case <-stop:
+				return
+			}
+		}
+	}()
 
-		updated++
+	var wg sync.WaitGroup
+	for range max(1, min(jobs, len(fetch))) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for q := range work {
+				q.opts.Progress.Status("Downloading")
+				q.err = install.Fetch(q.opts)
+				fetched <- q
+			}
+		}()
 	}
+	go func() {
+		wg.Wait()
+		close(fetched)
+	}()
 
-	if err := state.Save(); err != nil {
-		return fmt.Errorf("saving state: %w", err)
+	for lead := range fetched {
+		for _, q := range append([]*queuedUpdate{lead}, followers[lead]...) {
+			q.err = lead.err
+			if err := installQueued(state, q); err != nil {
+				for _, q := range queue {
+					if q.res.Status == "" {
+						q.opts.Progress.StopWithError("Skipped")
+						q.res = q.res.with(statusSkipped, "")
+					}
+				}
+				mp.Stop()
+				return err
+			}
+		}
 	}
+	return nil
+}
 
-	fmt.Println()
-	if updated == 0 {
-		ui.Successf("All packages are up to date.")
-	} else {
-		ui.Successf("Updated %d package(s).", updated)
+// installQueued installs an update whose download is done, recording the
+// outcome in q.res. The error is returned only if state could not be saved,
+// after the install has been rolled back.
+func installQueued(state *store.State, q *queuedUpdate) error {
+	row := q.opts.Progress
+	if q.err != nil {
+		row.StopWithError(q.err.Error())
+		q.res = q.res.failed(q.err)
+		return nil
+	}
+
+	row.Status("Installing")
+	result, err := install.Run(q.opts)
+	if err != nil {
+		row.StopWithError(err.Error())
+		q.res = q.res.failed(err)
+		return nil
+	}
+
+	state.Add(q.id, &store.Package{
+		Pubkey:       q.app.Pubkey,
+		Version:      q.release.Version,
+		Executables:  result.Executables,
+		Extras:       result.Extras,
+		AssetEventID: q.asset.Event.ID,
+		Constraint:   q.pkg.Constraint,
+		Links:        result.Links,
+	})
+
+	// Save after each package so a later failure can't lose this one
+	if err := state.Save(); err != nil {
+		err = fmt.Errorf("saving state: %w", err)
+		row.StopWithError("State not saved")
+		q.res = q.res.failed(err)
+		if rerr := result.Rollback(); rerr != nil {
+			ui.Errorf("rollback: %v", rerr)
+		}
+		return err
 	}
+	result.Commit(state.Get(q.id).Versions())
 
+	row.StopWithSuccess(fmt.Sprintf("%s %s %s", ui.Dim("v"+q.pkg.Version), ui.Arrow(), ui.Bold("v"+q.release.Version)))
+	q.res = q.res.with(statusUpdated, q.release.Version)
 	return nil
 }
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gonostr "github.com/nbd-wtf/go-nostr"
+	"github.com/zapstore/zapstore/nostr"
+	"github.com/zapstore/zapstore/store"
+	"github.com/zapstore/zapstore/ui"
+)
+
+// assetServer serves each registered body at its path, slowly enough that
+// downloads overlap, and records how many run at once and how often each
+// path is fetched.
+type assetServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	bodies   map[string][]byte
+	hits     map[string]int
+	inFlight int
+	peak     int
+}
+
+func newAssetServer(t *testing.T) *assetServer {
+	s := &assetServer{bodies: map[string][]byte{}, hits: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.hits[r.URL.Path]++
+		s.inFlight++
+		s.peak = max(s.peak, s.inFlight)
+		body := s.bodies[r.URL.Path]
+		s.mu.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+		w.Write(body)
+
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// queue adds an update of appID from 1.0 to 2.0 whose asset holds body,
+// installed as name.
+func (s *assetServer) queue(state *store.State, appID, name, body string) *queuedUpdate {
+	sum := sha256.Sum256([]byte(body))
+	hash := hex.EncodeToString(sum[:])
+	s.mu.Lock()
+	s.bodies["/"+hash] = []byte(body)
+	s.mu.Unlock()
+
+	pkg := &store.Package{Version: "1.0"}
+	state.Add(appID, pkg)
+	return &queuedUpdate{
+		pendingUpdate: &pendingUpdate{
+			app:     &nostr.AppInfo{AppID: appID, Pubkey: "pk"},
+			release: &nostr.ReleaseInfo{Version: "2.0"},
+			asset:   &nostr.AssetInfo{Event: &gonostr.Event{ID: "asset-" + appID}, URL: s.URL + "/" + hash, Hash: hash, Filename: name},
+		},
+		id:  appID,
+		pkg: pkg,
+		res: updateJSON{AppID: appID, Version: "1.0"},
+	}
+}
+
+func updateEnv(t *testing.T) string {
+	t.Helper()
+	for _, env := range []string{"XDG_STATE_HOME", "XDG_DATA_HOME", "XDG_CONFIG_HOME", "XDG_CACHE_HOME"} {
+		t.Setenv(env, t.TempDir())
+	}
+	ui.JSON = true // keep the progress rows off the test output
+	t.Cleanup(func() { ui.JSON = false })
+	dataDir, err := store.DataDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dataDir
+}
+
+func TestApplyUpdates(t *testing.T) {
+	dataDir := updateEnv(t)
+	srv := newAssetServer(t)
+	state := &store.State{Packages: map[string]*store.Package{}}
+	queue := []*queuedUpdate{
+		srv.queue(state, "com.example.a", "a", "#!/bin/sh\necho a\n"),
+		// Same asset as a: downloaded once, not by two workers at once
+		srv.queue(state, "com.example.a2", "a2", "#!/bin/sh\necho a\n"),
+		srv.queue(state, "com.example.b", "b", "#!/bin/sh\necho b\n"),
+		srv.queue(state, "com.example.c", "c", "#!/bin/sh\necho c\n"),
+		srv.queue(state, "com.example.d", "d", "#!/bin/sh\necho d\n"),
+	}
+
+	if err := applyUpdates(state, queue, 2); err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range queue {
+		if q.res.Status != statusUpdated || q.res.Available != "2.0" {
+			t.Errorf("%s: %+v, want updated to 2.0", q.id, q.res)
+		}
+		if target, err := os.Readlink(filepath.Join(dataDir, "bin", q.asset.Filename)); err != nil || !strings.Contains(target, q.id) {
+			t.Errorf("bin/%s → %q, %v; want a link into %s", q.asset.Filename, target, err, q.id)
+		}
+	}
+
+	if srv.peak > 2 {
+		t.Errorf("%d downloads ran at once, want at most 2", srv.peak)
+	}
+	for path, n := range srv.hits {
+		if n != 1 {
+			t.Errorf("%s downloaded %d times, want once", path, n)
+		}
+	}
+	if len(srv.hits) != 4 {
+		t.Errorf("%d assets downloaded, want the 4 distinct ones", len(srv.hits))
+	}
+
+	// Each install was saved as it finished
+	saved, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range queue {
+		if pkg := saved.Get(q.id); pkg == nil || pkg.Version != "2.0" {
+			t.Errorf("saved state holds %s as %+v, want v2.0", q.id, pkg)
+		}
+	}
+}
+
+func TestApplyUpdatesSaveFailure(t *testing.T) {
+	dataDir := updateEnv(t)
+	srv := newAssetServer(t)
+	state := &store.State{Packages: map[string]*store.Package{}}
+	queue := []*queuedUpdate{
+		srv.queue(state, "com.example.a", "a", "#!/bin/sh\necho a\n"),
+		srv.queue(state, "com.example.b", "b", "#!/bin/sh\necho b\n"),
+		srv.queue(state, "com.example.c", "c", "#!/bin/sh\necho c\n"),
+	}
+
+	// state.json is a directory, so no save can rename over it
+	stateDir, _ := store.StateDir()
+	if err := os.MkdirAll(filepath.Join(stateDir, "state.json", "blocked"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	err := applyUpdates(state, queue, 2)
+	if err == nil || !strings.Contains(err.Error(), "saving state") {
+		t.Fatalf("applyUpdates = %v, want a save error", err)
+	}
+
+	// The update whose save failed is reported failed, the rest skipped:
+	// none is left without a status
+	failed := 0
+	for _, q := range queue {
+		switch q.res.Status {
+		case statusFailed:
+			failed++
+			if q.res.Error == nil || !strings.Contains(q.res.Error.Message, "saving state") {
+				t.Errorf("%s failed with %+v, want the save error", q.id, q.res.Error)
+			}
+		case statusSkipped:
+		default:
+			t.Errorf("%s has status %q, want failed or skipped", q.id, q.res.Status)
+		}
+	}
+	if failed != 1 {
+		t.Errorf("%d updates failed, want only the one whose save failed", failed)
+	}
+
+	// Its install was rolled back
+	if entries, _ := os.ReadDir(filepath.Join(dataDir, "bin")); len(entries) != 0 {
+		t.Errorf("bin holds %d links after the rollback, want none", len(entries))
+	}
+}
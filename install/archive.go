@@ -0,0 +1,523 @@
+package install
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"github.com/zapstore/zapstore/ui"
+)
+
+// maxExtractSize caps the total uncompressed size of an archive, guarding
+// against decompression bombs.
+const maxExtractSize = 4 << 30
+
+// format describes how an asset is packaged.
+type format struct {
+	archive     string // "tar", "zip", or "" for a single file
+	compression string // "gzip", "xz", "zstd", "bzip2", or "" if uncompressed
+}
+
+// isArchive reports whether the asset contains multiple files to extract.
+func (f format) isArchive() bool { return f.archive != "" }
+
+func (f format) String() string {
+	switch {
+	case f.archive == "zip":
+		return "zip"
+	case f.archive == "tar" && f.compression != "":
+		return "tar." + f.compression
+	case f.archive == "tar":
+		return "tar"
+	case f.compression != "":
+		return f.compression
+	default:
+		return "binary"
+	}
+}
+
+// Magic numbers used to identify formats from file contents.
+var (
+	magicGzip = []byte{0x1f, 0x8b}
+	magicXz   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	magicZstd = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	magicBz2  = []byte("BZh")
+	magicZip  = []byte{'P', 'K', 0x03, 0x04}
+	magicTar  = []byte("ustar") // at offset 257
+)
+
+// tarMIMEs are `m` tag values that announce a (possibly compressed) tarball.
+var tarMIMEs = map[string]bool{
+	"application/x-tar":                 true,
+	"application/x-gtar":                true,
+	"application/x-compressed-tar":      true,
+	"application/x-xz-compressed-tar":   true,
+	"application/x-zstd-compressed-tar": true,
+	"application/x-bzip-compressed-tar": true,
+}
+
+// compressionSuffixes are stripped from single-file compressed assets to
+// get the binary name (tool-linux-x86_64.gz → tool-linux-x86_64).
+var compressionSuffixes = []string{".gz", ".xz", ".zst", ".zstd", ".bz2"}
+
+// detectFormat identifies an asset's packaging. Magic bytes are
+// authoritative for compression and zip; whether a compressed stream holds a
+// tarball is decided by peeking at the decompressed header, with the
+// filename and MIME type as a hint for tarballs that lack a ustar header.
+func detectFormat(path, name, mime string) (format, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return format{}, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 512)
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return format{}, err
+	}
+	header = header[:n]
+
+	if bytes.HasPrefix(header, magicZip) {
+		return format{archive: "zip"}, nil
+	}
+
+	comp := compressionFromMagic(header)
+	if comp == "" {
+		if isTarHeader(header) {
+			return format{archive: "tar"}, nil
+		}
+		return format{}, nil
+	}
+
+	// Compressed: peek at the decompressed stream
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return format{}, err
+	}
+	r, err := decompressor(f, comp)
+	if err != nil {
+		return format{}, fmt.Errorf("reading %s stream: %w", comp, err)
+	}
+	defer r.Close()
+
+	inner := make([]byte, 512)
+	n, _ = io.ReadFull(r, inner)
+	if isTarHeader(inner[:n]) || looksLikeTarball(name, mime) {
+		return format{archive: "tar", compression: comp}, nil
+	}
+	return format{compression: comp}, nil
+}
+
+func compressionFromMagic(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, magicGzip):
+		return "gzip"
+	case bytes.HasPrefix(header, magicXz):
+		return "xz"
+	case bytes.HasPrefix(header, magicZstd):
+		return "zstd"
+	case bytes.HasPrefix(header, magicBz2) && len(header) > 3 && header[3] >= '1' && header[3] <= '9':
+		return "bzip2"
+	}
+	return ""
+}
+
+func isTarHeader(header []byte) bool {
+	return len(header) >= 262 && bytes.Equal(header[257:262], magicTar)
+}
+
+// looksLikeTarball reports whether the asset's filename or MIME type says it
+// is a tarball.
+func looksLikeTarball(name, mime string) bool {
+	if tarMIMEs[strings.ToLower(strings.TrimSpace(strings.Split(mime, ";")[0]))] {
+		return true
+	}
+	name = strings.ToLower(name)
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz", ".tar.xz", ".txz", ".tar.zst", ".tzst", ".tar.bz2", ".tbz2"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// decompressor wraps r in a reader for the given compression.
+func decompressor(r io.Reader, comp string) (io.ReadCloser, error) {
+	switch comp {
+	case "gzip":
+		return gzip.NewReader(r)
+	case "xz":
+		xr, err := xz.NewReader(bufio.NewReader(r))
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xr), nil
+	case "zstd":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	case "bzip2":
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	case "":
+		return io.NopCloser(r), nil
+	}
+	return nil, fmt.Errorf("unsupported compression %q", comp)
+}
+
+// decompressFile writes the decompressed contents of a single-file
+// compressed asset to dst with the given mode, replacing dst atomically.
+func decompressFile(src, dst, comp string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	r, err := decompressor(in, comp)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	tmp := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp")
+	os.Remove(tmp)
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp) // no-op once renamed into place
+
+	n, err := io.Copy(out, io.LimitReader(r, maxExtractSize+1))
+	if err == nil && n > maxExtractSize {
+		err = fmt.Errorf("decompressed size exceeds %s", ui.FormatBytes(maxExtractSize))
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// trimCompressionSuffix strips a compression extension from a file name.
+// The name is returned unchanged if stripping would leave nothing.
+func trimCompressionSuffix(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range compressionSuffixes {
+		if strings.HasSuffix(lower, ext) && len(name) > len(ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
+
+// extract unpacks an archive into destDir. Entries that would land outside
+// destDir — absolute paths, ".." segments, or links pointing out — are
+// rejected rather than skipped.
+func extract(path, destDir string, af format) error {
+	switch af.archive {
+	case "tar":
+		return extractTar(path, destDir, af.compression)
+	case "zip":
+		return extractZip(path, destDir)
+	}
+	return fmt.Errorf("not an archive")
+}
+
+func extractTar(path, destDir, comp string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := decompressor(f, comp)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var total int64
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading tar: %w", err)
+		}
+
+		target, err := safeJoin(destDir, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+
+		case tar.TypeReg:
+			total += hdr.Size
+			if total > maxExtractSize {
+				return fmt.Errorf("archive exceeds %s uncompressed", ui.FormatBytes(maxExtractSize))
+			}
+			if err := writeFile(target, tr, fileMode(hdr.FileInfo().Mode())); err != nil {
+				return err
+			}
+
+		case tar.TypeSymlink:
+			if err := safeSymlink(destDir, target, hdr.Linkname); err != nil {
+				return err
+			}
+
+		case tar.TypeLink:
+			src, err := safeJoin(destDir, hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			os.Remove(target)
+			if err := os.Link(src, target); err != nil {
+				return err
+			}
+
+		default:
+			// Devices, FIFOs and the like have no place in a package
+		}
+	}
+}
+
+func extractZip(path, destDir string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("reading zip: %w", err)
+	}
+	defer zr.Close()
+
+	var total int64
+	for _, zf := range zr.File {
+		target, err := safeJoin(destDir, zf.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := zf.Mode()
+		switch {
+		case mode.IsDir():
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+
+		case mode&fs.ModeSymlink != 0:
+			rc, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			link, err := io.ReadAll(io.LimitReader(rc, 4096))
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			if err := safeSymlink(destDir, target, string(link)); err != nil {
+				return err
+			}
+
+		case mode.IsRegular():
+			total += int64(zf.UncompressedSize64)
+			if total > maxExtractSize {
+				return fmt.Errorf("archive exceeds %s uncompressed", ui.FormatBytes(maxExtractSize))
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			err = writeFile(target, rc, fileMode(mode))
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// safeJoin joins an archive entry name onto destDir, refusing names that
+// are absolute, climb out of destDir, or pass through a symlink extracted
+// earlier (which could otherwise redirect writes outside destDir).
+func safeJoin(destDir, name string) (string, error) {
+	name = filepath.FromSlash(name)
+	if filepath.IsAbs(name) || strings.HasPrefix(name, string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %q has an absolute path", name)
+	}
+	target := filepath.Join(destDir, name)
+	rel, err := filepath.Rel(destDir, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %q escapes the install directory", name)
+	}
+
+	dir := destDir
+	parts := strings.Split(rel, string(filepath.Separator))
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		if info, err := os.Lstat(dir); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("archive entry %q is inside a symlinked directory", name)
+		}
+	}
+	return target, nil
+}
+
+// safeSymlink creates a symlink at target pointing to linkname, which must
+// be relative and resolve inside destDir.
+func safeSymlink(destDir, target, linkname string) error {
+	if filepath.IsAbs(linkname) {
+		return fmt.Errorf("archive symlink %q has an absolute target", linkname)
+	}
+	resolved := filepath.Join(filepath.Dir(target), linkname)
+	rel, err := filepath.Rel(destDir, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("archive symlink %q escapes the install directory", linkname)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	os.Remove(target)
+	return os.Symlink(linkname, target)
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	os.Remove(target) // don't write through a symlink planted by an earlier entry
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// fileMode keeps only permission bits, always readable and writable by the
+// owner, with no setuid/setgid/sticky bits.
+func fileMode(m os.FileMode) os.FileMode {
+	return m.Perm() | 0o600
+}
+
+// Magic numbers of native executables.
+var execMagics = [][]byte{
+	{0x7f, 'E', 'L', 'F'},    // ELF
+	{0xfe, 0xed, 0xfa, 0xce}, // Mach-O 32-bit
+	{0xfe, 0xed, 0xfa, 0xcf}, // Mach-O 64-bit
+	{0xce, 0xfa, 0xed, 0xfe}, // Mach-O 32-bit, little-endian
+	{0xcf, 0xfa, 0xed, 0xfe}, // Mach-O 64-bit, little-endian
+	{0xca, 0xfe, 0xba, 0xbe}, // Mach-O universal
+}
+
+// findExecutables returns the paths, relative to dir, of the executables to
+// link into bin/. If the asset declares them, each declared name must match
+// a file by relative path or base name. Otherwise every ELF or Mach-O file
+// with an exec bit set is returned.
+func findExecutables(dir string, declared []string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	if len(declared) > 0 {
+		var exes []string
+		for _, want := range declared {
+			want = filepath.Clean(filepath.FromSlash(want))
+			match := ""
+			for _, rel := range files {
+				if rel == want || filepath.Base(rel) == want {
+					match = rel
+					break
+				}
+			}
+			if match == "" {
+				return nil, fmt.Errorf("declared executable %q not found in archive", want)
+			}
+			if err := os.Chmod(filepath.Join(dir, match), 0o755); err != nil {
+				return nil, err
+			}
+			exes = append(exes, match)
+		}
+		return exes, nil
+	}
+
+	var exes []string
+	for _, rel := range files {
+		path := filepath.Join(dir, rel)
+		info, err := os.Stat(path)
+		if err != nil || info.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+		if isNativeExecutable(path) {
+			exes = append(exes, rel)
+		}
+	}
+	if len(exes) == 0 {
+		return nil, fmt.Errorf("no executables found in archive")
+	}
+	return exes, nil
+}
+
+func isNativeExecutable(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+	for _, m := range execMagics {
+		if bytes.Equal(header, m) {
+			return true
+		}
+	}
+	return false
+}
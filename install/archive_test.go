@@ -0,0 +1,211 @@
+package install
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var elfHeader = []byte{0x7f, 'E', 'L', 'F', 2, 1, 1, 0}
+
+type entry struct {
+	name     string
+	body     []byte
+	mode     int64
+	typeflag byte
+	link     string
+}
+
+func writeTarGz(t *testing.T, path string, entries []entry) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		typ := e.typeflag
+		if typ == 0 {
+			typ = tar.TypeReg
+		}
+		hdr := &tar.Header{Name: e.name, Mode: e.mode, Size: int64(len(e.body)), Typeflag: typ, Linkname: e.link}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(e.body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+}
+
+func TestExtractTarGz(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "tool.tar.gz")
+	writeTarGz(t, archive, []entry{
+		{name: "tool-1.0/", typeflag: tar.TypeDir, mode: 0o755},
+		{name: "tool-1.0/bin/tool", body: elfHeader, mode: 0o755},
+		{name: "tool-1.0/bin/helper", body: elfHeader, mode: 0o755},
+		{name: "tool-1.0/README", body: []byte("hi"), mode: 0o644},
+		{name: "tool-1.0/script.sh", body: []byte("#!/bin/sh\n"), mode: 0o755},
+	})
+
+	af, err := detectFormat(archive, "asset", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if af != (format{archive: "tar", compression: "gzip"}) {
+		t.Fatalf("detectFormat = %v, want tar.gzip", af)
+	}
+
+	dest := filepath.Join(dir, "out")
+	if err := extract(archive, dest, af); err != nil {
+		t.Fatal(err)
+	}
+
+	exes, err := findExecutables(dest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"tool-1.0/bin/helper", "tool-1.0/bin/tool"}
+	if !reflect.DeepEqual(exes, want) {
+		t.Errorf("findExecutables = %v, want %v", exes, want)
+	}
+
+	exes, err = findExecutables(dest, []string{"tool"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exes, []string{"tool-1.0/bin/tool"}) {
+		t.Errorf("declared executables = %v", exes)
+	}
+
+	if _, err := findExecutables(dest, []string{"missing"}); err == nil {
+		t.Error("expected error for missing declared executable")
+	}
+}
+
+func TestExtractRejectsTraversal(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []entry
+	}{
+		{"dotdot", []entry{{name: "../evil", body: []byte("x"), mode: 0o644}}},
+		{"absolute", []entry{{name: "/tmp/evil", body: []byte("x"), mode: 0o644}}},
+		{"symlink out", []entry{{name: "link", typeflag: tar.TypeSymlink, link: "../../etc"}}},
+		{"absolute symlink", []entry{{name: "link", typeflag: tar.TypeSymlink, link: "/etc"}}},
+		{"through symlink", []entry{
+			{name: "link", typeflag: tar.TypeSymlink, link: "."},
+			{name: "link/x", typeflag: tar.TypeSymlink, link: "../"},
+		}},
+		{"hardlink out", []entry{{name: "link", typeflag: tar.TypeLink, link: "../../etc/passwd"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			archive := filepath.Join(dir, "a.tar.gz")
+			writeTarGz(t, archive, tt.entries)
+
+			err := extract(archive, filepath.Join(dir, "out"), format{archive: "tar", compression: "gzip"})
+			if err == nil {
+				t.Error("expected extraction to be rejected")
+			}
+		})
+	}
+}
+
+func TestExtractZip(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "tool.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	hdr := &zip.FileHeader{Name: "tool", Method: zip.Deflate}
+	hdr.SetMode(0o755)
+	w, err := zw.CreateHeader(hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(elfHeader)
+	zw.Close()
+	f.Close()
+
+	af, err := detectFormat(archive, "tool.zip", "application/zip")
+	if err != nil || af.archive != "zip" {
+		t.Fatalf("detectFormat = %v, %v", af, err)
+	}
+
+	dest := filepath.Join(dir, "out")
+	if err := extract(archive, dest, af); err != nil {
+		t.Fatal(err)
+	}
+	exes, err := findExecutables(dest, nil)
+	if err != nil || !reflect.DeepEqual(exes, []string{"tool"}) {
+		t.Errorf("findExecutables = %v, %v", exes, err)
+	}
+}
+
+func TestDetectFormatBareBinary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tool")
+	os.WriteFile(path, elfHeader, 0o755)
+
+	af, err := detectFormat(path, "tool", "application/x-executable; format=elf; arch=x86-64")
+	if err != nil || af.isArchive() || af.compression != "" {
+		t.Errorf("detectFormat = %v, %v; want plain binary", af, err)
+	}
+}
+
+func TestSingleFileCompressed(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tool-linux-x86_64.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write(elfHeader)
+	gz.Close()
+	f.Close()
+
+	af, err := detectFormat(path, "tool-linux-x86_64.gz", "application/gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if af != (format{compression: "gzip"}) {
+		t.Fatalf("detectFormat = %v, want single gzip file", af)
+	}
+
+	dst := filepath.Join(dir, trimCompressionSuffix("tool-linux-x86_64.gz"))
+	if err := decompressFile(path, dst, af.compression, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if !isNativeExecutable(dst) {
+		t.Error("decompressed file is not the original binary")
+	}
+}
+
+func TestTrimCompressionSuffix(t *testing.T) {
+	tests := map[string]string{
+		"tool-linux-x86_64.gz": "tool-linux-x86_64",
+		"tool.XZ":              "tool",
+		"tool.zst":             "tool",
+		"tool.bz2":             "tool",
+		"tool":                 "tool",
+		".gz":                  ".gz",
+	}
+	for in, want := range tests {
+		if got := trimCompressionSuffix(in); got != want {
+			t.Errorf("trimCompressionSuffix(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
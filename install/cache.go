@@ -0,0 +1,253 @@
+package install
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zapstore/zapstore/store"
+)
+
+// ErrNotCached is wrapped by the error returned when an offline install
+// needs an asset that is not in the download cache.
+var ErrNotCached = errors.New("not in the download cache")
+
+// Blob is a verified download kept in the content-addressed cache.
+type Blob struct {
+	Hash     string
+	Path     string
+	Size     int64
+	LastUsed time.Time
+}
+
+// lookupBlob returns the path of a cached blob with the given SHA-256 if it
+// exists and still hashes correctly. Corrupt blobs are removed. A hit bumps
+// the blob's mtime so pruning by age keeps recently used entries.
+func lookupBlob(expectedHash string) (string, bool) {
+	if expectedHash == "" {
+		return "", false
+	}
+	dir, err := store.BlobDir()
+	if err != nil {
+		return "", false
+	}
+	path := filepath.Join(dir, strings.ToLower(expectedHash))
+
+	sum, err := hashFile(path)
+	if err != nil {
+		return "", false
+	}
+	if verifyHash(sum, expectedHash) != nil {
+		os.Remove(path)
+		return "", false
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return path, true
+}
+
+// storeBlob moves a verified download into the cache and returns its new path.
+func storeBlob(src, hash string) (string, error) {
+	dir, err := store.BlobDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("creating cache directory: %w", err)
+	}
+	dst := filepath.Join(dir, strings.ToLower(hash))
+	if err := moveFile(src, dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// placeFile copies a cached blob to dst with the given mode. It is a copy
+// rather than a hard link so that changing the installed file, its mode
+// included, cannot corrupt the cache.
+func placeFile(src, dst string, mode os.FileMode) error {
+	tmp, err := copyTemp(src, dst, mode)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// CacheList returns all cached blobs, most recently used first.
+func CacheList() ([]Blob, error) {
+	dir, err := store.BlobDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var blobs []Blob
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		blobs = append(blobs, Blob{
+			Hash:     e.Name(),
+			Path:     filepath.Join(dir, e.Name()),
+			Size:     info.Size(),
+			LastUsed: info.ModTime(),
+		})
+	}
+
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].LastUsed.After(blobs[j].LastUsed)
+	})
+	return blobs, nil
+}
+
+// CachePrune removes blobs not used within maxAge, then the least recently
+// used blobs until the cache fits in maxSize bytes. A zero limit is ignored.
+// Interrupted downloads are pruned by age as well.
+func CachePrune(maxSize int64, maxAge time.Duration) (removed int, bytesFreed int64, err error) {
+	blobs, err := CacheList()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var total int64
+	for _, b := range blobs {
+		total += b.Size
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+
+	// Walk oldest first so the size limit evicts least recently used blobs
+	for i := len(blobs) - 1; i >= 0; i-- {
+		b := blobs[i]
+		expired := maxAge > 0 && b.LastUsed.Before(cutoff)
+		oversize := maxSize > 0 && total > maxSize
+		if !expired && !oversize {
+			continue
+		}
+		if err := os.Remove(b.Path); err != nil {
+			continue
+		}
+		removed++
+		bytesFreed += b.Size
+		total -= b.Size
+	}
+
+	if maxAge > 0 {
+		n, freed := prunePartials(maxAge)
+		removed += n
+		bytesFreed += freed
+	}
+
+	return removed, bytesFreed, nil
+}
+
+// CacheClear removes every cached blob and interrupted download.
+func CacheClear() (removed int, bytesFreed int64, err error) {
+	blobs, err := CacheList()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, b := range blobs {
+		if os.Remove(b.Path) == nil {
+			removed++
+			bytesFreed += b.Size
+		}
+	}
+
+	n, freed := prunePartials(0)
+	return removed + n, bytesFreed + freed, nil
+}
+
+// prunePartials removes interrupted downloads not written to within maxAge.
+// A zero maxAge removes all of them.
+func prunePartials(maxAge time.Duration) (removed int, bytesFreed int64) {
+	dir, err := store.PartialDir()
+	if err != nil {
+		return 0, 0
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, 0
+	}
+	cutoff := time.Now().Add(-maxAge)
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if os.Remove(filepath.Join(dir, e.Name())) == nil {
+			removed++
+			bytesFreed += info.Size()
+		}
+	}
+	return removed, bytesFreed
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyTemp copies src to a new temporary file next to dst with the given
+// mode and returns its path, for the caller to rename into place.
+func copyTemp(src, dst string, mode os.FileMode) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmp := out.Name()
+
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Chmod(mode)
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return tmp, nil
+}
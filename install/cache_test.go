@@ -0,0 +1,66 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestPlaceFileConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "blob")
+	if err := os.WriteFile(src, []byte("payload"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "tool")
+
+	// Writers of the same destination must not share a temporary file
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := placeFile(src, dst, 0o755); err != nil {
+				errs <- err
+			}
+			if tmp, err := copyTemp(src, dst, 0o644); err != nil {
+				errs <- err
+			} else {
+				os.Remove(tmp)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	data, err := os.ReadFile(dst)
+	if err != nil || string(data) != "payload" {
+		t.Errorf("dst = %q, %v; want the blob's contents", data, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("directory holds %v, want only blob and tool", names)
+	}
+
+	// The installed file is separate from the cache blob
+	if info, err := os.Stat(src); err != nil {
+		t.Error(err)
+	} else if info.Mode().Perm() != 0o644 {
+		t.Errorf("blob mode = %v, want it left at 0644", info.Mode().Perm())
+	}
+	if err := os.WriteFile(dst, []byte("patched"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(src); string(data) != "payload" {
+		t.Errorf("writing the installed file changed the blob to %q", data)
+	}
+}
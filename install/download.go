@@ -0,0 +1,203 @@
+package install
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/zapstore/zapstore/store"
+	"github.com/zapstore/zapstore/ui"
+)
+
+// meter is where a download reports its progress: a ui.Progress bar of its
+// own, or the package's ui.Row when several downloads run at once.
+type meter interface {
+	io.Writer
+	SetTotal(total int64)
+	Add(n int64)
+	Start()
+	StopWithSuccess(message string)
+	StopWithError(message string)
+}
+
+// download fetches url into a partial file and returns its path, size and
+// SHA-256 hex digest, reporting progress to prog.
+//
+// When expectedHash is known the partial file is named after it, so an
+// interrupted download is kept and resumed on the next attempt with a Range
+// request. Servers that ignore Range get a full download. The returned digest
+// always covers the whole file, including bytes from earlier attempts.
+func download(url, expectedHash, label string, prog meter) (path string, n int64, sum string, err error) {
+	dir, err := store.PartialDir()
+	if err != nil {
+		return "", 0, "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", 0, "", fmt.Errorf("creating download directory: %w", err)
+	}
+
+	var f *os.File
+	if expectedHash != "" {
+		path = filepath.Join(dir, strings.ToLower(expectedHash)+".part")
+		f, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	} else {
+		f, err = os.CreateTemp(dir, "*.part")
+	}
+	if err != nil {
+		return "", 0, "", fmt.Errorf("opening partial file: %w", err)
+	}
+	path = f.Name()
+	defer func() {
+		if cerr := f.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+		// Without a hash there is nothing to resume against
+		if err != nil && expectedHash == "" {
+			os.Remove(path)
+		}
+	}()
+
+	// Re-hash whatever an earlier attempt left behind
+	h := sha256.New()
+	offset, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, "", fmt.Errorf("reading partial file: %w", err)
+	}
+
+	prog.Add(offset)
+	prog.Start()
+
+	n, err = fetch(url, f, h, offset, prog)
+	if err != nil {
+		prog.StopWithError(fmt.Sprintf("Download failed: %s", label))
+		return "", 0, "", err
+	}
+	if err := f.Sync(); err != nil {
+		prog.StopWithError(fmt.Sprintf("Download failed: %s", label))
+		return "", 0, "", err
+	}
+
+	msg := fmt.Sprintf("Downloaded %s (%s)", label, ui.FormatBytes(n))
+	if offset > 0 && n > offset {
+		msg += " " + ui.Dim(fmt.Sprintf("(resumed at %s)", ui.FormatBytes(offset)))
+	}
+	prog.StopWithSuccess(msg)
+
+	return path, n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fetch writes the remainder of url into f starting at offset, feeding the
+// same bytes into h and prog. If the server does not honor the Range request
+// the file and hash are reset and the whole body is written. Returns the
+// final file size.
+func fetch(url string, f *os.File, h hash.Hash, offset int64, prog meter) (int64, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return offset, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && rangeStart(resp) == offset:
+		if resp.ContentLength > 0 {
+			prog.SetTotal(offset + resp.ContentLength)
+		}
+
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// Either the partial file is already complete or it is longer than
+		// the remote file. Hand it back for hash verification either way;
+		// a mismatch discards it.
+		return offset, nil
+
+	case resp.StatusCode == http.StatusOK:
+		// Full body: start over
+		if offset > 0 {
+			if err := f.Truncate(0); err != nil {
+				return 0, err
+			}
+			h.Reset()
+			prog.Add(-offset)
+			offset = 0
+		}
+		if resp.ContentLength > 0 {
+			prog.SetTotal(resp.ContentLength)
+		}
+
+	default:
+		return offset, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+
+	n, err := io.Copy(io.MultiWriter(f, h, prog), resp.Body)
+	return offset + n, err
+}
+
+// rangeStart parses the first byte position of a Content-Range header
+// ("bytes 100-199/200"). Returns -1 if the header is missing or malformed.
+func rangeStart(resp *http.Response) int64 {
+	cr := strings.TrimPrefix(resp.Header.Get("Content-Range"), "bytes ")
+	i := strings.Index(cr, "-")
+	if i == -1 {
+		return -1
+	}
+	start, err := strconv.ParseInt(cr[:i], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return start
+}
+
+// moveFile renames src to dst, falling back to copy-then-rename when they
+// are on different filesystems. dst is replaced atomically either way.
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := copyTemp(src, dst, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// ErrHashMismatch is wrapped by the error returned when a download does not
+// match the SHA-256 hash from its signed asset event.
+var ErrHashMismatch = errors.New("hash mismatch")
+
+func verifyHash(got, expectedHex string) error {
+	if !strings.EqualFold(got, expectedHex) {
+		return fmt.Errorf("%w: expected %s, got %s", ErrHashMismatch, expectedHex, got)
+	}
+	return nil
+}
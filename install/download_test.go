@@ -0,0 +1,152 @@
+package install
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zapstore/zapstore/store"
+	"github.com/zapstore/zapstore/ui"
+)
+
+// nopMeter discards download progress.
+type nopMeter struct{}
+
+func (nopMeter) Write(p []byte) (int, error) { return len(p), nil }
+func (nopMeter) SetTotal(int64)              {}
+func (nopMeter) Add(int64)                   {}
+func (nopMeter) Start()                      {}
+func (nopMeter) StopWithSuccess(string)      {}
+func (nopMeter) StopWithError(string)        {}
+
+// blobServer serves body, honoring Range requests unless ignoreRange is
+// set, and records the Range header of each request.
+type blobServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	ranges []string
+}
+
+func newBlobServer(t *testing.T, body []byte, ignoreRange bool) *blobServer {
+	s := &blobServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		s.mu.Unlock()
+		if ignoreRange {
+			w.Write(body)
+			return
+		}
+		http.ServeContent(w, r, "blob", time.Time{}, bytes.NewReader(body))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// writePartial leaves data behind as an interrupted download of hash.
+func writePartial(t *testing.T, hash string, data []byte) string {
+	t.Helper()
+	dir, err := store.PartialDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, hash+".part")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDownloadResume(t *testing.T) {
+	body := []byte(strings.Repeat("zapstore blob ", 100))
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name        string
+		partial     []byte
+		ignoreRange bool
+		wantRange   string
+	}{
+		// 206: the rest of the file is appended to what is there
+		{"partial content", body[:500], false, "bytes=500-"},
+		// 200: the server sent everything, so the partial is replaced
+		{"range ignored", []byte("garbage that is not a prefix"), true, "bytes=28-"},
+		// 416: the partial is already the whole file
+		{"already complete", body, false, "bytes=1400-"},
+		{"fresh", nil, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+			srv := newBlobServer(t, body, tt.ignoreRange)
+			if tt.partial != nil {
+				writePartial(t, hash, tt.partial)
+			}
+
+			path, n, got, err := download(srv.URL, hash, "blob", nopMeter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != hash || n != int64(len(body)) {
+				t.Errorf("download = %d bytes, hash %s; want %d bytes, hash %s", n, got, len(body), hash)
+			}
+			if data, _ := os.ReadFile(path); !bytes.Equal(data, body) {
+				t.Errorf("partial file holds %d bytes, not the blob", len(data))
+			}
+			if len(srv.ranges) != 1 || srv.ranges[0] != tt.wantRange {
+				t.Errorf("requests sent Range %q, want [%q]", srv.ranges, tt.wantRange)
+			}
+		})
+	}
+}
+
+func TestFetchDiscardsCorruptPartial(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	ui.JSON = true // keep the progress rows off the test output
+	defer func() { ui.JSON = false }()
+
+	body := []byte("#!/bin/sh\necho tool\n")
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	srv := newBlobServer(t, body, false)
+
+	// Longer than the remote file: the server answers 416 and the hash
+	// check must reject it
+	part := writePartial(t, hash, append(bytes.Clone(body), "stale tail"...))
+
+	mp := ui.NewMultiProgress()
+	opts := Options{
+		AppID:    "com.example.tool",
+		Version:  "1.0",
+		URL:      srv.URL + "/tool",
+		Hash:     hash,
+		Progress: mp.Add("com.example.tool", "Waiting"),
+	}
+	if err := Fetch(opts); !errors.Is(err, ErrHashMismatch) {
+		t.Fatalf("Fetch over a stale partial = %v, want ErrHashMismatch", err)
+	}
+	if _, err := os.Stat(part); !os.IsNotExist(err) {
+		t.Errorf("corrupt partial was kept: %v", err)
+	}
+
+	// With the partial gone, the next attempt downloads afresh
+	if err := Fetch(opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lookupBlob(hash); !ok {
+		t.Error("asset not cached after the retry")
+	}
+}
@@ -0,0 +1,122 @@
+package install
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// extra is a man page or shell completion shipped inside an archive.
+type extra struct {
+	src string // relative to the version directory
+	dst string // relative to the data directory, under share/
+}
+
+// manPageRe matches man page file names such as tool.1, tool.8p or
+// tool.1.gz, capturing the section number.
+var manPageRe = regexp.MustCompile(`\.([1-9])[a-z]*(\.gz)?$`)
+
+// manDirRe matches directories man pages are shipped in: man/, man1/, and
+// share/man/man1/ all qualify.
+var manDirRe = regexp.MustCompile(`(^|/)man[1-9]?(/|$)`)
+
+// findExtras scans an extracted archive for man pages and shell completions
+// and returns where each should be linked:
+//
+//	share/man/man<N>/<page>                     ← man pages
+//	share/bash-completion/completions/<cmd>     ← bash
+//	share/zsh/site-functions/_<cmd>             ← zsh
+//	share/fish/vendor_completions.d/<cmd>.fish  ← fish
+func findExtras(pkgDir string) ([]extra, error) {
+	var extras []extra
+	err := filepath.WalkDir(pkgDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(pkgDir, path)
+		if err != nil {
+			return err
+		}
+		if dst := extraDest(rel); dst != "" {
+			extras = append(extras, extra{src: rel, dst: dst})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(extras, func(i, j int) bool { return extras[i].dst < extras[j].dst })
+	return extras, nil
+}
+
+// extraDest classifies a file from an archive by its name and the
+// directories it sits in. Returns "" if it is neither a man page nor a
+// completion script.
+func extraDest(rel string) string {
+	name := filepath.Base(rel)
+	dir := strings.ToLower(filepath.ToSlash(filepath.Dir(rel)))
+	inComplDir := strings.Contains(dir, "complet")
+
+	if m := manPageRe.FindStringSubmatch(name); m != nil && manDirRe.MatchString(dir) {
+		return filepath.Join("share", "man", "man"+m[1], name)
+	}
+
+	switch {
+	case strings.HasSuffix(name, ".fish"):
+		return filepath.Join("share", "fish", "vendor_completions.d", name)
+
+	case strings.HasSuffix(name, ".bash"):
+		return filepath.Join("share", "bash-completion", "completions", strings.TrimSuffix(name, ".bash"))
+
+	case strings.HasSuffix(name, ".zsh"):
+		cmd := strings.TrimSuffix(name, ".zsh")
+		if !strings.HasPrefix(cmd, "_") {
+			cmd = "_" + cmd
+		}
+		return filepath.Join("share", "zsh", "site-functions", cmd)
+
+	case strings.HasPrefix(name, "_") && !strings.Contains(name, ".") && (inComplDir || strings.Contains(dir, "zsh")):
+		return filepath.Join("share", "zsh", "site-functions", name)
+
+	case !strings.Contains(name, ".") && strings.Contains(dir, "bash") && inComplDir:
+		return filepath.Join("share", "bash-completion", "completions", name)
+	}
+	return ""
+}
+
+// removeDanglingLinks removes symlinks under dir whose targets no longer
+// exist, then any directories left empty. Returns the number of links removed.
+func removeDanglingLinks(dir string) int {
+	removed := 0
+	var dirs []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if os.Remove(path) == nil {
+				removed++
+			}
+		}
+		return nil
+	})
+
+	// Deepest first so parents empty out after their children
+	for i := len(dirs) - 1; i > 0; i-- {
+		os.Remove(dirs[i]) // only if empty
+	}
+	return removed
+}
@@ -0,0 +1,28 @@
+package install
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestExtraDest(t *testing.T) {
+	tests := map[string]string{
+		"tool-1.0/man/man1/tool.1":   "share/man/man1/tool.1",
+		"share/man/man5/toolrc.5.gz": "share/man/man5/toolrc.5.gz",
+		"man/tool.8":                 "share/man/man8/tool.8",
+		"completions/_tool":          "share/zsh/site-functions/_tool",
+		"completions/tool.bash":      "share/bash-completion/completions/tool",
+		"completions/tool.fish":      "share/fish/vendor_completions.d/tool.fish",
+		"contrib/tool.zsh":           "share/zsh/site-functions/_tool",
+		"autocomplete/bash/tool":     "share/bash-completion/completions/tool",
+		"tool-1.0/tool":              "",
+		"lib/libtool.so.1":           "",
+		"README.md":                  "",
+		"src/_internal":              "",
+	}
+	for in, want := range tests {
+		if got := extraDest(in); filepath.ToSlash(got) != want {
+			t.Errorf("extraDest(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
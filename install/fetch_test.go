@@ -0,0 +1,58 @@
+package install
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zapstore/zapstore/ui"
+)
+
+func TestFetch(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	ui.JSON = true // keep the progress rows off the test output
+	defer func() { ui.JSON = false }()
+
+	body := []byte("#!/bin/sh\necho tool\n")
+	sum := sha256.Sum256(body)
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	mp := ui.NewMultiProgress()
+	opts := Options{
+		AppID:    "com.example.tool",
+		Version:  "1.0",
+		URL:      srv.URL + "/tool",
+		Hash:     hex.EncodeToString(sum[:]),
+		Progress: mp.Add("com.example.tool", "Waiting"),
+	}
+
+	if err := Fetch(opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lookupBlob(opts.Hash); !ok {
+		t.Fatal("downloaded asset is not in the cache")
+	}
+
+	// A second fetch, even offline, is served from the cache
+	opts.Offline = true
+	if err := Fetch(opts); err != nil {
+		t.Fatal(err)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("server hit %d times, want 1", n)
+	}
+
+	opts.Hash = hex.EncodeToString(make([]byte, 32))
+	if err := Fetch(opts); !errors.Is(err, ErrNotCached) {
+		t.Errorf("offline fetch of an uncached asset = %v, want ErrNotCached", err)
+	}
+}
@@ -2,38 +2,80 @@
 package install
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/zapstore/zapstore/store"
 	"github.com/zapstore/zapstore/ui"
+	"github.com/zapstore/zapstore/validate"
 )
 
 // Options configures an install operation.
 type Options struct {
-	AppID    string
-	Version  string
-	URL      string
-	Hash     string // expected SHA-256 hex
-	Filename string // from asset's filename tag
-	Pubkey   string
-	EventID  string
+	AppID       string
+	Version     string
+	URL         string
+	Hash        string   // expected SHA-256 hex
+	Filename    string   // from asset's filename tag
+	Size        int64    // from asset's size tag, 0 if unknown
+	MIME        string   // from asset's m tag
+	Executables []string // from asset's executables tag, for archives
+	Pubkey      string
+	EventID     string
+
+	// Offline fails the install rather than downloading an asset that is
+	// not in the download cache.
+	Offline bool
+
+	// Progress, if set, is the package's row in a ui.MultiProgress. The
+	// download and each stage are reported there instead of being printed.
+	Progress *ui.Row
+
+	// Previous is the active version being replaced, if any. Its links
+	// that the new version does not provide are removed. If it predates
+	// recorded links, its Links are filled in from disk so it can be
+	// switched back to later.
+	Previous *store.Package
 }
 
 // Result holds information about a completed install.
 type Result struct {
-	BinaryPath  string
+	BinaryPath  string // first executable
 	SymlinkPath string
 	BinaryName  string
+
+	// Executables are the names of all links created in bin/.
+	Executables []string
+
+	// Extras are the man page and completion links created under share/,
+	// relative to the data directory.
+	Extras []string
+
+	// Links maps each link above, relative to the data directory, to its
+	// target relative to the version directory.
+	Links map[string]string
+
+	baseDir  string
+	appID    string
+	version  string
+	pkgDir   string // created by this install; empty for a switch
+	replaced string // same-version directory moved aside, until Commit
+	links    linkJournal
 }
 
-// Run downloads, verifies, and installs a binary.
+// Run downloads, verifies, and installs a binary or archive.
+//
+// Archives (tar.gz, tar.xz, tar.zst, zip) are extracted into the version
+// directory and every executable in them is linked into bin/.
+//
+// The asset is unpacked into a staging directory that is renamed into place
+// only when complete, and each symlink is swapped atomically. If anything
+// fails the links are restored and the new version removed. On success the
+// previous version is left on disk: callers must Commit once state is saved,
+// or Rollback if saving fails. Commit also removes versions no longer kept.
 //
 // Filesystem layout:
 //
@@ -45,74 +87,235 @@ func Run(opts Options) (*Result, error) {
 		return nil, err
 	}
 
-	// Determine the binary name: prefer explicit filename, fall back to URL, then app ID
-	binaryName := opts.Filename
-	if binaryName == "" {
-		binaryName = binaryNameFromURL(opts.URL)
-	}
-	if binaryName == "" {
-		binaryName = opts.AppID
+	assetName, err := checkNames(opts)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create version directory
-	pkgDir := filepath.Join(baseDir, "packages", opts.AppID, opts.Version)
-	if err := os.MkdirAll(pkgDir, 0o755); err != nil {
+	// Unpack into a staging directory next to the final one
+	appDir := filepath.Join(baseDir, "packages", opts.AppID)
+	if err := os.MkdirAll(appDir, 0o755); err != nil {
 		return nil, fmt.Errorf("creating install directory: %w", err)
 	}
+	staging, err := os.MkdirTemp(appDir, ".staging-")
+	if err != nil {
+		return nil, fmt.Errorf("creating staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging) // no-op once promoted
+	if err := os.Chmod(staging, 0o755); err != nil {
+		return nil, err
+	}
 
-	binaryPath := filepath.Join(pkgDir, binaryName)
-
-	// Download
-	sp := ui.NewSpinner(fmt.Sprintf("Downloading %s...", binaryName))
-	sp.Start()
-	data, err := download(opts.URL)
+	exes, extras, err := unpack(opts, assetName, staging)
 	if err != nil {
-		sp.StopWithError(fmt.Sprintf("Download failed: %s", binaryName))
-		return nil, fmt.Errorf("downloading: %w", err)
+		return nil, err
 	}
-	sp.StopWithSuccess(fmt.Sprintf("Downloaded %s (%s)", binaryName, formatBytes(int64(len(data)))))
 
-	// Verify hash
-	if opts.Hash != "" {
-		if err := verifyHash(data, opts.Hash); err != nil {
-			// Clean up on hash mismatch
-			os.RemoveAll(pkgDir)
+	// Check executable names before touching anything live
+	var names []string
+	for _, rel := range exes {
+		name := filepath.Base(rel)
+		if err := validate.FileName(name); err != nil {
 			return nil, err
 		}
-		ui.Infof("Hash verified %s", ui.Dim("(SHA-256)"))
-	}
-
-	// Write binary
-	if err := os.WriteFile(binaryPath, data, 0o755); err != nil {
-		return nil, fmt.Errorf("writing binary: %w", err)
+		if slices.Contains(names, name) {
+			return nil, fmt.Errorf("archive contains two executables named %q", name)
+		}
+		names = append(names, name)
 	}
 
-	// Create symlink
 	binDir := filepath.Join(baseDir, "bin")
 	if err := os.MkdirAll(binDir, 0o755); err != nil {
 		return nil, fmt.Errorf("creating bin directory: %w", err)
 	}
 
-	symlinkPath := filepath.Join(binDir, binaryName)
-	os.Remove(symlinkPath)
+	result := &Result{
+		baseDir: baseDir,
+		appID:   opts.AppID,
+		version: opts.Version,
+		pkgDir:  filepath.Join(appDir, opts.Version),
+	}
+	if err := result.promote(staging); err != nil {
+		return nil, fmt.Errorf("moving %s into place: %w", opts.Version, err)
+	}
+
+	var previous []string
+	if opts.Previous != nil {
+		if opts.Previous.Links == nil {
+			opts.Previous.Links = readLinks(baseDir, opts.AppID, opts.Previous)
+		}
+		previous = linkPaths(opts.Previous)
+	}
+
+	// Swap symlinks
+	result.Links = make(map[string]string)
+	for i, rel := range exes {
+		result.Links["bin/"+names[i]] = filepath.ToSlash(rel)
+	}
+	for _, e := range extras {
+		result.Links[filepath.ToSlash(e.dst)] = filepath.ToSlash(e.src)
+	}
+	if err := result.relink(result.pkgDir, result.Links, previous); err != nil {
+		result.Rollback()
+		return nil, err
+	}
+
+	result.BinaryPath = filepath.Join(result.pkgDir, exes[0])
+	result.SymlinkPath = filepath.Join(binDir, names[0])
+	result.BinaryName = names[0]
+	result.Executables = names
+	for _, e := range extras {
+		result.Extras = append(result.Extras, e.dst)
+	}
+
+	return result, nil
+}
+
+// Fetch puts the asset of opts into the download cache, downloading and
+// verifying it unless an entry with its hash is already there. Run does
+// this itself; calling Fetch first lets several downloads run in parallel
+// while the installs that follow stay one at a time.
+func Fetch(opts Options) error {
+	assetName, err := checkNames(opts)
+	if err != nil {
+		return err
+	}
+	_, err = fetchAsset(opts, assetName)
+	return err
+}
+
+// checkNames returns the asset's file name, preferring the explicit
+// filename, then the URL, then the app ID. Everything it checks becomes a
+// path segment, so names are re-checked even though the nostr package
+// already validated them.
+func checkNames(opts Options) (string, error) {
+	assetName := opts.Filename
+	if assetName == "" {
+		assetName = binaryNameFromURL(opts.URL)
+	}
+	if assetName == "" {
+		assetName = opts.AppID
+	}
+
+	if err := validate.AppID(opts.AppID); err != nil {
+		return "", err
+	}
+	if err := validate.Version(opts.Version); err != nil {
+		return "", err
+	}
+	if err := validate.FileName(assetName); err != nil {
+		return "", err
+	}
+	return assetName, nil
+}
+
+// status reports an install stage: on the package's progress row, or as
+// an info line.
+func (opts Options) status(format string, args ...any) {
+	if opts.Progress != nil {
+		opts.Progress.Status(fmt.Sprintf(format, args...))
+		return
+	}
+	ui.Infof(format, args...)
+}
+
+// unpack fetches the asset and lays it out in pkgDir: archives are
+// extracted, single compressed files are decompressed, and anything else is
+// placed as is. Returns the executables' paths relative to pkgDir, plus
+// any man pages and completions found in an archive.
+func unpack(opts Options, assetName, pkgDir string) ([]string, []extra, error) {
+	blob, err := fetchAsset(opts, assetName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	af, err := detectFormat(blob, assetName, opts.MIME)
+	if err != nil {
+		return nil, nil, fmt.Errorf("inspecting %s: %w", assetName, err)
+	}
+
+	if !af.isArchive() {
+		// The hash was checked on the compressed bytes; decompress after
+		if af.compression != "" {
+			binaryName := trimCompressionSuffix(assetName)
+			opts.status("Decompressing %s %s", assetName, ui.Dim("("+af.String()+")"))
+			if err := decompressFile(blob, filepath.Join(pkgDir, binaryName), af.compression, 0o755); err != nil {
+				return nil, nil, fmt.Errorf("decompressing %s: %w", assetName, err)
+			}
+			return []string{binaryName}, nil, nil
+		}
+
+		if err := placeFile(blob, filepath.Join(pkgDir, assetName), 0o755); err != nil {
+			return nil, nil, fmt.Errorf("writing binary: %w", err)
+		}
+		return []string{assetName}, nil, nil
+	}
+
+	opts.status("Extracting %s %s", assetName, ui.Dim("("+af.String()+")"))
+	if err := extract(blob, pkgDir, af); err != nil {
+		return nil, nil, fmt.Errorf("extracting %s: %w", assetName, err)
+	}
+	exes, err := findExecutables(pkgDir, opts.Executables)
+	if err != nil {
+		return nil, nil, err
+	}
+	extras, err := findExtras(pkgDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return exes, extras, nil
+}
+
+// fetchAsset returns the path of the asset in the download cache, taking it
+// from there when an entry with the expected hash exists and downloading it
+// (resuming an earlier partial download if there is one) otherwise.
+func fetchAsset(opts Options, label string) (string, error) {
+	if blob, ok := lookupBlob(opts.Hash); ok {
+		opts.status("Using cached %s %s", label, ui.Dim("(SHA-256 verified)"))
+		return blob, nil
+	}
+	if opts.Offline {
+		return "", fmt.Errorf("%s v%s: %w (offline)", opts.AppID, opts.Version, ErrNotCached)
+	}
 
-	relTarget := filepath.Join("..", "packages", opts.AppID, opts.Version, binaryName)
-	if err := os.Symlink(relTarget, symlinkPath); err != nil {
-		return nil, fmt.Errorf("creating symlink: %w", err)
+	var prog meter
+	if opts.Progress != nil {
+		opts.Progress.SetTotal(opts.Size)
+		prog = opts.Progress
+	} else {
+		prog = ui.NewProgress(label, opts.Size)
+	}
+	partial, _, sum, err := download(opts.URL, opts.Hash, label, prog)
+	if err != nil {
+		return "", fmt.Errorf("downloading: %w", err)
 	}
 
-	// Clean up old versions of this app (keep only the one just installed)
-	cleanupOldVersions(baseDir, opts.AppID, opts.Version)
+	// Verify hash over the whole file
+	if opts.Hash != "" {
+		if err := verifyHash(sum, opts.Hash); err != nil {
+			// A corrupt partial must not be resumed again
+			os.Remove(partial)
+			return "", err
+		}
+		opts.status("Hash verified %s", ui.Dim("(SHA-256)"))
+	}
 
-	return &Result{
-		BinaryPath:  binaryPath,
-		SymlinkPath: symlinkPath,
-		BinaryName:  binaryName,
-	}, nil
+	// Blobs are named by their actual content, so an unverified download
+	// can still be cached safely
+	blob, err := storeBlob(partial, sum)
+	if err != nil {
+		return "", fmt.Errorf("caching download: %w", err)
+	}
+	return blob, nil
 }
 
-// Uninstall removes the app directory, symlinks, and state entry.
-func Uninstall(appID string, executables []string) error {
+// Uninstall removes the app directory, its bin/ symlinks, and any man page
+// or completion links under share/ (given relative to the data directory).
+func Uninstall(appID string, executables, extras []string) error {
+	if err := validate.AppID(appID); err != nil {
+		return err
+	}
+
 	baseDir, err := store.DataDir()
 	if err != nil {
 		return err
@@ -137,11 +340,44 @@ func Uninstall(appID string, executables []string) error {
 		}
 	}
 
+	// Remove man page and completion links from share/
+	for _, rel := range extras {
+		linkPath := filepath.Join(baseDir, filepath.FromSlash(rel))
+		target, err := os.Readlink(linkPath)
+		if err != nil {
+			continue
+		}
+		if strings.Contains(target, appID+"/") {
+			os.Remove(linkPath)
+		}
+	}
+	removeDanglingLinks(filepath.Join(baseDir, "share"))
+
 	return nil
 }
 
+// Retain removes the version directories of an app other than keep, along
+// with any links left dangling by them.
+func Retain(appID string, keep []string) error {
+	if err := validate.AppID(appID); err != nil {
+		return err
+	}
+	baseDir, err := store.DataDir()
+	if err != nil {
+		return err
+	}
+	retain(baseDir, appID, keep)
+	return nil
+}
+
+func retain(baseDir, appID string, keep []string) {
+	cleanupOldVersions(baseDir, appID, keep)
+	removeDanglingLinks(filepath.Join(baseDir, "bin"))
+	removeDanglingLinks(filepath.Join(baseDir, "share"))
+}
+
 // Cleanup removes old version directories that are not referenced by any
-// symlink or state entry. Returns the number of directories removed and
+// symlink or kept in state. Returns the number of directories removed and
 // total bytes freed.
 func Cleanup() (removed int, bytesFreed int64, err error) {
 	baseDir, err := store.DataDir()
@@ -163,6 +399,10 @@ func Cleanup() (removed int, bytesFreed int64, err error) {
 		return 0, 0, err
 	}
 
+	// Versions that bin/ links still point at are in use even if state
+	// disagrees, e.g. after a crash between swapping links and saving state
+	linked := linkedVersions(baseDir)
+
 	for _, appEntry := range apps {
 		if !appEntry.IsDir() {
 			continue
@@ -175,10 +415,10 @@ func Cleanup() (removed int, bytesFreed int64, err error) {
 			continue
 		}
 
-		// Determine the active version from state
-		activeVersion := ""
+		// Determine the active and retained versions from state
+		var kept []string
 		if pkg := state.Get(appID); pkg != nil {
-			activeVersion = pkg.Version
+			kept = pkg.Versions()
 		}
 
 		for _, verEntry := range versions {
@@ -186,11 +426,11 @@ func Cleanup() (removed int, bytesFreed int64, err error) {
 				continue
 			}
 			ver := verEntry.Name()
-			if ver == activeVersion {
+			if slices.Contains(kept, ver) || linked[appID+"/"+ver] {
 				continue
 			}
 
-			// This version is not active — remove it
+			// This version is neither active nor kept — remove it
 			versionDir := filepath.Join(appDir, ver)
 			size := dirSize(versionDir)
 			if err := os.RemoveAll(versionDir); err == nil {
@@ -201,7 +441,7 @@ func Cleanup() (removed int, bytesFreed int64, err error) {
 
 		// If the app has no state entry at all (orphaned), and the
 		// directory is now empty, remove the app directory too.
-		if activeVersion == "" {
+		if kept == nil {
 			remaining, _ := os.ReadDir(appDir)
 			if len(remaining) == 0 {
 				os.Remove(appDir)
@@ -210,22 +450,10 @@ func Cleanup() (removed int, bytesFreed int64, err error) {
 	}
 
 	// Clean up dangling symlinks in bin/
-	binDir := filepath.Join(baseDir, "bin")
-	links, err := os.ReadDir(binDir)
-	if err == nil {
-		for _, link := range links {
-			linkPath := filepath.Join(binDir, link.Name())
-			target, err := os.Readlink(linkPath)
-			if err != nil {
-				continue
-			}
-			// Resolve relative to binDir
-			abs := filepath.Join(binDir, target)
-			if _, err := os.Stat(abs); os.IsNotExist(err) {
-				os.Remove(linkPath)
-			}
-		}
-	}
+	removeDanglingLinks(filepath.Join(baseDir, "bin"))
+
+	// Clean up dangling man page and completion links in share/
+	removeDanglingLinks(filepath.Join(baseDir, "share"))
 
 	return removed, bytesFreed, nil
 }
@@ -235,43 +463,20 @@ func Cleanup() (removed int, bytesFreed int64, err error) {
 // --------------------------------------------------------------------------
 
 // cleanupOldVersions removes version directories for an app other than the
-// specified current version.
-func cleanupOldVersions(baseDir, appID, currentVersion string) {
+// ones to keep.
+func cleanupOldVersions(baseDir, appID string, keep []string) {
 	appDir := filepath.Join(baseDir, "packages", appID)
 	entries, err := os.ReadDir(appDir)
 	if err != nil {
 		return
 	}
 	for _, entry := range entries {
-		if entry.IsDir() && entry.Name() != currentVersion {
+		if entry.IsDir() && !slices.Contains(keep, entry.Name()) {
 			os.RemoveAll(filepath.Join(appDir, entry.Name()))
 		}
 	}
 }
 
-func download(url string) ([]byte, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
-	}
-
-	return io.ReadAll(resp.Body)
-}
-
-func verifyHash(data []byte, expectedHex string) error {
-	h := sha256.Sum256(data)
-	got := hex.EncodeToString(h[:])
-	if got != expectedHex {
-		return fmt.Errorf("hash mismatch: expected %s, got %s", expectedHex, got)
-	}
-	return nil
-}
-
 func binaryNameFromURL(url string) string {
 	parts := strings.Split(url, "/")
 	if len(parts) == 0 {
@@ -284,6 +489,29 @@ func binaryNameFromURL(url string) string {
 	return name
 }
 
+// linkedVersions returns the "<app-id>/<version>" pairs that symlinks in
+// bin/ currently point into.
+func linkedVersions(baseDir string) map[string]bool {
+	linked := make(map[string]bool)
+	binDir := filepath.Join(baseDir, "bin")
+	links, err := os.ReadDir(binDir)
+	if err != nil {
+		return linked
+	}
+	for _, link := range links {
+		target, err := os.Readlink(filepath.Join(binDir, link.Name()))
+		if err != nil {
+			continue
+		}
+		// ../packages/<app-id>/<version>/...
+		parts := strings.Split(filepath.ToSlash(target), "/")
+		if len(parts) >= 4 && parts[0] == ".." && parts[1] == "packages" {
+			linked[parts[2]+"/"+parts[3]] = true
+		}
+	}
+	return linked
+}
+
 // dirSize returns the total size of files in a directory tree.
 func dirSize(path string) int64 {
 	var size int64
@@ -295,17 +523,3 @@ func dirSize(path string) int64 {
 	})
 	return size
 }
-
-// formatBytes formats bytes into human-readable form.
-func formatBytes(b int64) string {
-	const unit = 1024
-	if b < unit {
-		return fmt.Sprintf("%d B", b)
-	}
-	div, exp := int64(unit), 0
-	for n := b / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
-}
@@ -0,0 +1,162 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/zapstore/zapstore/store"
+	"github.com/zapstore/zapstore/validate"
+)
+
+// Switch makes a version of an app that is still on disk the active one,
+// without any network access: to's recorded links are pointed into its
+// version directory and from's links that to does not provide are removed.
+// As with Run, callers must Commit once state is saved, or Rollback.
+func Switch(appID string, from, to *store.Package) (*Result, error) {
+	if err := validate.AppID(appID); err != nil {
+		return nil, err
+	}
+	if err := validate.Version(to.Version); err != nil {
+		return nil, err
+	}
+	if len(to.Links) == 0 {
+		return nil, fmt.Errorf("no links recorded for %s v%s; reinstall it with 'zapstore install %s@%s'",
+			appID, to.Version, appID, to.Version)
+	}
+
+	baseDir, err := store.DataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	versionDir := filepath.Join(baseDir, "packages", appID, to.Version)
+	if info, err := os.Stat(versionDir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%s v%s is no longer on disk", appID, to.Version)
+	}
+
+	// Recorded state could have been edited; keep every path inside its root
+	for link, target := range to.Links {
+		if !filepath.IsLocal(filepath.FromSlash(link)) || !filepath.IsLocal(filepath.FromSlash(target)) {
+			return nil, fmt.Errorf("recorded link %s → %s escapes the data directory", link, target)
+		}
+	}
+
+	if from.Links == nil {
+		from.Links = readLinks(baseDir, appID, from)
+	}
+
+	result := &Result{
+		baseDir: baseDir,
+		appID:   appID,
+		version: to.Version,
+		Links:   to.Links,
+	}
+	if err := result.relink(versionDir, to.Links, linkPaths(from)); err != nil {
+		result.Rollback()
+		return nil, err
+	}
+
+	for _, link := range linkPaths(to) {
+		if name, ok := strings.CutPrefix(link, "bin/"); ok {
+			result.Executables = append(result.Executables, name)
+		} else {
+			result.Extras = append(result.Extras, filepath.FromSlash(link))
+		}
+	}
+	if len(result.Executables) > 0 {
+		result.BinaryName = result.Executables[0]
+		result.SymlinkPath = filepath.Join(baseDir, "bin", result.BinaryName)
+		result.BinaryPath = filepath.Join(versionDir, filepath.FromSlash(to.Links["bin/"+result.BinaryName]))
+	}
+	return result, nil
+}
+
+// relink points each link in links (relative to the data directory) at its
+// target inside versionDir, then removes any of the previous links that are
+// not replaced and still point into the app. Every change goes through the
+// journal.
+func (r *Result) relink(versionDir string, links map[string]string, previous []string) error {
+	paths := make([]string, 0, len(links))
+	for link := range links {
+		paths = append(paths, link)
+	}
+	slices.Sort(paths)
+
+	for _, link := range paths {
+		linkPath := filepath.Join(r.baseDir, filepath.FromSlash(link))
+		if err := os.MkdirAll(filepath.Dir(linkPath), 0o755); err != nil {
+			return fmt.Errorf("creating %s: %w", filepath.Dir(link), err)
+		}
+
+		target, err := filepath.Rel(filepath.Dir(linkPath), filepath.Join(versionDir, filepath.FromSlash(links[link])))
+		if err != nil {
+			return err
+		}
+		if err := r.links.swap(target, linkPath); err != nil {
+			return fmt.Errorf("linking %s: %w", link, err)
+		}
+	}
+
+	appDir := filepath.Join(r.baseDir, "packages", r.appID) + string(filepath.Separator)
+	for _, link := range previous {
+		if _, ok := links[link]; ok {
+			continue
+		}
+		linkPath := filepath.Join(r.baseDir, filepath.FromSlash(link))
+		target, err := os.Readlink(linkPath)
+		if err != nil {
+			continue
+		}
+		if !strings.HasPrefix(filepath.Join(filepath.Dir(linkPath), target), appDir) {
+			continue // taken over by another app
+		}
+		if err := r.links.remove(linkPath); err != nil {
+			return fmt.Errorf("removing %s: %w", link, err)
+		}
+	}
+	return nil
+}
+
+// linkPaths returns the links a package version owns, relative to the data
+// directory, falling back to its executables and extras when it predates
+// recorded links.
+func linkPaths(pkg *store.Package) []string {
+	var paths []string
+	if pkg.Links != nil {
+		for link := range pkg.Links {
+			paths = append(paths, link)
+		}
+	} else {
+		for _, exe := range pkg.Executables {
+			paths = append(paths, "bin/"+exe)
+		}
+		for _, extra := range pkg.Extras {
+			paths = append(paths, filepath.ToSlash(extra))
+		}
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// readLinks reconstructs a version's links from the ones on disk that point
+// into its version directory.
+func readLinks(baseDir, appID string, pkg *store.Package) map[string]string {
+	versionDir := filepath.Join(baseDir, "packages", appID, pkg.Version)
+	links := make(map[string]string)
+	for _, link := range linkPaths(pkg) {
+		linkPath := filepath.Join(baseDir, filepath.FromSlash(link))
+		target, err := os.Readlink(linkPath)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(versionDir, filepath.Join(filepath.Dir(linkPath), target))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		links[link] = filepath.ToSlash(rel)
+	}
+	return links
+}
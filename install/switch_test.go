@@ -0,0 +1,83 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zapstore/zapstore/store"
+)
+
+func TestSwitch(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	baseDir, _ := store.DataDir()
+
+	write := func(rel string) {
+		p := filepath.Join(baseDir, "packages", "app", rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("1.0/tool")
+	write("2.0/tool-2.0/bin/tool")
+	write("2.0/tool-2.0/tool.1")
+
+	old := &store.Package{Version: "1.0", Links: map[string]string{"bin/tool": "tool"}}
+	cur := &store.Package{Version: "2.0", Executables: []string{"tool"}, Extras: []string{"share/man/man1/tool.1"}}
+
+	// Lay out 2.0's links as an install would have
+	r := &Result{baseDir: baseDir, appID: "app"}
+	versionDir := filepath.Join(baseDir, "packages", "app", "2.0")
+	err := r.relink(versionDir, map[string]string{
+		"bin/tool":              "tool-2.0/bin/tool",
+		"share/man/man1/tool.1": "tool-2.0/tool.1",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Switch("app", cur, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if target, _ := os.Readlink(filepath.Join(baseDir, "bin", "tool")); target != filepath.Join("..", "packages", "app", "1.0", "tool") {
+		t.Errorf("bin/tool → %s, want 1.0", target)
+	}
+	if _, err := os.Lstat(filepath.Join(baseDir, "share", "man", "man1", "tool.1")); !os.IsNotExist(err) {
+		t.Error("man page only 2.0 ships should be unlinked")
+	}
+	if cur.Links["share/man/man1/tool.1"] != "tool-2.0/tool.1" {
+		t.Errorf("links of the version switched away from not recorded: %v", cur.Links)
+	}
+
+	// Rolling back a switch leaves both versions and restores 2.0's links
+	if err := res.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if target, _ := os.Readlink(filepath.Join(baseDir, "bin", "tool")); target != filepath.Join("..", "packages", "app", "2.0", "tool-2.0", "bin", "tool") {
+		t.Errorf("after rollback bin/tool → %s, want 2.0", target)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "share", "man", "man1", "tool.1")); err != nil {
+		t.Errorf("man page not restored: %v", err)
+	}
+	for _, v := range []string{"1.0", "2.0"} {
+		if _, err := os.Stat(filepath.Join(baseDir, "packages", "app", v)); err != nil {
+			t.Errorf("version %s removed by rollback", v)
+		}
+	}
+}
+
+func TestSwitchRejectsEscapingLinks(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	baseDir, _ := store.DataDir()
+	os.MkdirAll(filepath.Join(baseDir, "packages", "app", "1.0"), 0o755)
+
+	to := &store.Package{Version: "1.0", Links: map[string]string{"../../etc/tool": "tool"}}
+	if _, err := Switch("app", &store.Package{Version: "2.0"}, to); err == nil {
+		t.Error("expected error for a link outside the data directory")
+	}
+}
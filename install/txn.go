@@ -0,0 +1,127 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// linkChange records the state of a symlink before an install touched it.
+type linkChange struct {
+	path    string
+	prev    string // previous target, if existed
+	existed bool
+}
+
+// linkJournal records every symlink an install swaps so the change can be
+// undone if a later step fails.
+type linkJournal struct {
+	changes []linkChange
+}
+
+// swap atomically points linkPath at target: the new link is created under
+// a temporary name and renamed over the old one, so there is never a moment
+// without a working link.
+func (j *linkJournal) swap(target, linkPath string) error {
+	prev, err := os.Readlink(linkPath)
+	j.changes = append(j.changes, linkChange{path: linkPath, prev: prev, existed: err == nil})
+
+	return replaceSymlink(target, linkPath)
+}
+
+// remove deletes the link at linkPath, recording its target.
+func (j *linkJournal) remove(linkPath string) error {
+	prev, err := os.Readlink(linkPath)
+	if err != nil {
+		return nil // nothing to remove
+	}
+	j.changes = append(j.changes, linkChange{path: linkPath, prev: prev, existed: true})
+
+	return os.Remove(linkPath)
+}
+
+// undo restores every recorded link to its previous target, newest first.
+func (j *linkJournal) undo() {
+	for i := len(j.changes) - 1; i >= 0; i-- {
+		c := j.changes[i]
+		if c.existed {
+			replaceSymlink(c.prev, c.path)
+		} else {
+			os.Remove(c.path)
+		}
+	}
+	j.changes = nil
+}
+
+// replaceSymlink creates a symlink to target under a temporary name next to
+// linkPath and renames it into place.
+func replaceSymlink(target, linkPath string) error {
+	tmp := filepath.Join(filepath.Dir(linkPath), "."+filepath.Base(linkPath)+".tmp")
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, linkPath); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// promote moves a fully unpacked staging directory to pkgDir. An existing
+// directory for the same version is moved aside rather than deleted, so it
+// can be restored by Rollback.
+func (r *Result) promote(staging string) error {
+	if _, err := os.Lstat(r.pkgDir); err == nil {
+		aside, err := os.MkdirTemp(filepath.Dir(r.pkgDir), ".old-"+filepath.Base(r.pkgDir)+"-")
+		if err != nil {
+			return err
+		}
+		os.Remove(aside)
+		if err := os.Rename(r.pkgDir, aside); err != nil {
+			return err
+		}
+		r.replaced = aside
+	}
+
+	if err := os.Rename(staging, r.pkgDir); err != nil {
+		if r.replaced != "" {
+			os.Rename(r.replaced, r.pkgDir)
+			r.replaced = ""
+		}
+		return err
+	}
+	return nil
+}
+
+// Commit finalizes an install or switch once the new state has been saved:
+// versions of the app other than keep are removed, along with links left
+// dangling by them. Until Commit is called the previous version stays on
+// disk so Rollback can return to it.
+func (r *Result) Commit(keep []string) {
+	retain(r.baseDir, r.appID, keep)
+	r.links.changes = nil
+	r.replaced = ""
+}
+
+// Rollback undoes an install or switch that has not been committed: every
+// symlink is pointed back at the previous version and a newly installed
+// version directory is removed (or, if it replaced a directory for the same
+// version, restored).
+func (r *Result) Rollback() error {
+	r.links.undo()
+
+	if r.pkgDir == "" {
+		return nil
+	}
+	if err := os.RemoveAll(r.pkgDir); err != nil {
+		return fmt.Errorf("removing %s: %w", r.pkgDir, err)
+	}
+	if r.replaced != "" {
+		if err := os.Rename(r.replaced, r.pkgDir); err != nil {
+			return fmt.Errorf("restoring %s: %w", r.pkgDir, err)
+		}
+		r.replaced = ""
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package install
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/zapstore/zapstore/store"
+	"github.com/zapstore/zapstore/ui"
+)
+
+// txnEnv points the data, cache and state directories at a fresh temporary
+// tree and returns the data directory.
+func txnEnv(t *testing.T) string {
+	t.Helper()
+	for _, env := range []string{"XDG_DATA_HOME", "XDG_CACHE_HOME", "XDG_STATE_HOME"} {
+		t.Setenv(env, t.TempDir())
+	}
+	ui.JSON = true // keep the install stages off the test output
+	t.Cleanup(func() { ui.JSON = false })
+	baseDir, err := store.DataDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return baseDir
+}
+
+// cachedAsset puts the file at path into the download cache and returns
+// options that install it offline as version ver.
+func cachedAsset(t *testing.T, path, ver string) Options {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if _, err := storeBlob(path, hash); err != nil {
+		t.Fatal(err)
+	}
+	return Options{
+		AppID:    "com.example.tool",
+		Version:  ver,
+		URL:      "https://example.invalid/" + filepath.Base(path),
+		Filename: filepath.Base(path),
+		Hash:     hash,
+		Offline:  true,
+	}
+}
+
+// bareAsset caches a single executable named tool holding body.
+func bareAsset(t *testing.T, ver, body string) Options {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tool")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return cachedAsset(t, path, ver)
+}
+
+// snapshot records every file, directory and symlink under root, with file
+// contents and link targets, so a rollback can be checked for exactness.
+func snapshot(t *testing.T, root string) map[string]string {
+	t.Helper()
+	tree := make(map[string]string)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			tree[rel] = "-> " + target
+			return err
+		case d.IsDir():
+			tree[rel] = "dir"
+		default:
+			data, err := os.ReadFile(path)
+			tree[rel] = string(data)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func checkSnapshot(t *testing.T, root string, want map[string]string) {
+	t.Helper()
+	got := snapshot(t, root)
+	if maps.Equal(got, want) {
+		return
+	}
+	for _, k := range slices.Sorted(maps.Keys(got)) {
+		if want[k] != got[k] {
+			t.Errorf("%s = %q, want %q", k, got[k], want[k])
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(want)) {
+		if _, ok := got[k]; !ok {
+			t.Errorf("%s missing, want %q", k, want[k])
+		}
+	}
+}
+
+// installFirst installs and commits version 1.0 of tool, returning it as
+// state would record it.
+func installFirst(t *testing.T) *store.Package {
+	t.Helper()
+	res, err := Run(bareAsset(t, "1.0", "v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Commit([]string{"1.0"})
+	return &store.Package{Version: "1.0", Executables: res.Executables, Links: res.Links}
+}
+
+func TestRunRollsBackFailedRelink(t *testing.T) {
+	baseDir := txnEnv(t)
+	prev := installFirst(t)
+
+	// 2.0 ships a man page, but share/man is a file, so linking it fails
+	// after bin/tool has already been swapped
+	if err := os.WriteFile(filepath.Join(baseDir, "share"), []byte("in the way"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	before := snapshot(t, baseDir)
+
+	archive := filepath.Join(t.TempDir(), "tool-2.0.tar.gz")
+	writeTarGz(t, archive, []entry{
+		{name: "tool-2.0/tool", body: []byte("v2"), mode: 0o755},
+		{name: "tool-2.0/man/tool.1", body: []byte(".TH TOOL 1"), mode: 0o644},
+	})
+	opts := cachedAsset(t, archive, "2.0")
+	opts.Executables = []string{"tool"}
+	opts.Previous = prev
+
+	_, err := Run(opts)
+	if err == nil || !strings.Contains(err.Error(), "share/man") {
+		t.Fatalf("Run = %v, want the man page link to fail", err)
+	}
+	checkSnapshot(t, baseDir, before)
+}
+
+func TestRollbackAfterSaveFailure(t *testing.T) {
+	baseDir := txnEnv(t)
+	prev := installFirst(t)
+
+	state := &store.State{Packages: map[string]*store.Package{}}
+	state.Add("com.example.tool", prev)
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+	before := snapshot(t, baseDir)
+
+	opts := bareAsset(t, "2.0", "v2")
+	opts.Previous = prev
+	res, err := Run(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target, _ := os.Readlink(filepath.Join(baseDir, "bin", "tool")); filepath.Base(filepath.Dir(target)) != "2.0" {
+		t.Fatalf("bin/tool → %s before saving, want 2.0", target)
+	}
+
+	// Replace state.json with a directory so saving cannot rename over it
+	stateDir, _ := store.StateDir()
+	statePath := filepath.Join(stateDir, "state.json")
+	if err := os.Remove(statePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(statePath, "blocked"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	state.Add("com.example.tool", &store.Package{Version: "2.0", Executables: res.Executables, Links: res.Links})
+	if err := state.Save(); err == nil {
+		t.Fatal("state.Save succeeded, want it to fail")
+	}
+
+	if err := res.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	checkSnapshot(t, baseDir, before)
+}
+
+func TestReinstallSameVersion(t *testing.T) {
+	baseDir := txnEnv(t)
+	prev := installFirst(t)
+
+	// Something only the installed copy of 1.0 has
+	marker := filepath.Join(baseDir, "packages", "com.example.tool", "1.0", "marker")
+	if err := os.WriteFile(marker, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	before := snapshot(t, baseDir)
+
+	opts := bareAsset(t, "1.0", "v1 rebuilt")
+	opts.Previous = prev
+	res, err := Run(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("the reinstalled 1.0 still holds the old directory's files")
+	}
+
+	// Saving state failed: the old directory comes back as it was
+	if err := res.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	checkSnapshot(t, baseDir, before)
+
+	// Committed, the old directory is gone for good
+	res, err = Run(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Commit([]string{"1.0"})
+	entries, _ := os.ReadDir(filepath.Join(baseDir, "packages", "com.example.tool"))
+	if len(entries) != 1 || entries[0].Name() != "1.0" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("app directory holds %v after commit, want only 1.0", names)
+	}
+	if data, _ := os.ReadFile(filepath.Join(baseDir, "packages", "com.example.tool", "1.0", "tool")); string(data) != "v1 rebuilt" {
+		t.Errorf("1.0/tool = %q after commit, want the reinstalled file", data)
+	}
+}
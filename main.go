@@ -1,10 +1,15 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/zapstore/zapstore/cmd"
+	"github.com/zapstore/zapstore/nostr"
 	"github.com/zapstore/zapstore/store"
 	"github.com/zapstore/zapstore/ui"
 )
@@ -12,19 +17,49 @@ import (
 const usage = `zapstore - a Nostr-based package manager
 
 Usage:
-  zapstore <command> [arguments]
+  zapstore [--wait] [--json] [--offline] <command> [arguments]
 
 Commands:
-  install <app-id>     Install a package
-  update  [<app-id>]   Update one or all installed packages
+  install [--pre] <app-id>[@<version>]
+                       Install a package, optionally at a version or range (^1.2);
+                       --pre allows pre-releases this once
+  update  [--dry-run] [--jobs <n>] [<app-id>]
+                       Update one or all installed packages, or only show what would change;
+                       --jobs sets how many downloads run at once (default 4)
+  outdated             List packages with updates available
   remove  <app-id>     Remove an installed package
   list                 List installed packages
-  search  <query>      Search for packages on the relay
-  cleanup              Remove old versions and dangling symlinks
+  search  <query>      Search for packages on the relays
+  switch  <app-id> <version>
+                       Activate another version kept on disk
+  rollback <app-id>    Return to the previously active version
+  keep    <app-id> [<n>]
+                       Show or set how many versions to keep (default 3)
+  pin     <app-id> [<constraint>]
+                       Hold a package at its version, or within a range
+  unpin   <app-id>     Let update move a package to the latest release
+  channel [<app-id>] [<name>]
+                       Show or set the release channel (stable, beta, rc, pre)
+  cleanup              Remove versions no longer kept and dangling symlinks
+  trust   <app-id>     Accept a new publisher key for a package
+  cache   ls|prune|clear
+                       Inspect or trim the download cache
+
+Options:
+  --wait               Wait for another running zapstore instead of failing
+  --json               Print one JSON document to stdout instead of text
+  --offline            Use cached relay events and downloads only
 `
 
+// exitUpdatesAvailable is the exit status of outdated and update --dry-run
+// when packages can be updated; errors exit with 1.
+const exitUpdatesAvailable = 2
+
 func main() {
-	if len(os.Args) < 2 {
+	args, wait := extractFlag(os.Args[1:], "--wait")
+	args, ui.JSON = extractFlag(args, "--json")
+	args, nostr.Offline = extractFlag(args, "--offline")
+	if len(args) < 1 {
 		fmt.Print(usage)
 		os.Exit(1)
 	}
@@ -35,57 +70,251 @@ func main() {
 		// Non-fatal: continue even if migration fails
 	}
 
-	var err error
+	// Commands that touch packages, state or the cache run one at a time
+	var lock *store.Lock
+	if mutates(args) {
+		var err error
+		if lock, err = acquireLock(wait); err != nil {
+			fail(err)
+		}
+	}
+
+	err := run(args)
+	lock.Release()
+	if err != nil {
+		fail(err)
+	}
+}
+
+// fail reports err and exits. In JSON mode it is printed to stdout as
+// {"error": {"code": ..., "message": ...}}, unless the command already
+// printed its own document.
+func fail(err error) {
+	status := exitStatus(err)
+	switch {
+	case ui.JSON:
+		if !ui.JSONWritten() {
+			ui.PrintJSON(map[string]any{"error": cmd.NewJSONError(err)})
+		}
+	case status == exitUpdatesAvailable:
+		fmt.Fprintf(os.Stderr, "%s %v\n", ui.Warn(), err)
+	default:
+		fmt.Fprintf(os.Stderr, "\n%s %v\n", ui.Cross(), err)
+	}
+	os.Exit(status)
+}
+
+func run(args []string) error {
+	// Shared by every relay query of the command; connects on first use
+	client := nostr.NewClient(nostr.Relays())
+	defer client.Close()
 
-	switch os.Args[1] {
+	switch args[0] {
 	case "install":
-		if len(os.Args) < 3 {
-			fatal("usage: zapstore install <app-id>")
+		rest, pre := extractFlag(args[1:], "--pre")
+		if len(rest) < 1 {
+			fatal("usage: zapstore install [--pre] <app-id>[@<version>]")
 		}
-		err = cmd.Install(os.Args[2])
+		return cmd.Install(client, rest[0], pre)
 
 	case "update":
+		rest, dryRun := extractFlag(args[1:], "--dry-run")
+		rest, jobsArg, ok := extractValue(rest, "--jobs")
+		jobs := cmd.DefaultJobs
+		if ok {
+			n, err := strconv.Atoi(jobsArg)
+			if err != nil || n < 1 {
+				fatal("usage: zapstore update [--dry-run] [--jobs <n>] [<app-id>]")
+			}
+			jobs = n
+		}
 		appID := ""
-		if len(os.Args) >= 3 {
-			appID = os.Args[2]
+		if len(rest) >= 1 {
+			appID = rest[0]
 		}
-		err = cmd.Update(appID)
+		return cmd.Update(client, appID, dryRun, jobs)
+
+	case "outdated":
+		return cmd.Outdated(client)
 
 	case "remove":
-		if len(os.Args) < 3 {
+		if len(args) < 2 {
 			fatal("usage: zapstore remove <app-id>")
 		}
-		err = cmd.Remove(os.Args[2])
+		return cmd.Remove(args[1])
 
 	case "list":
-		err = cmd.List()
+		return cmd.List()
 
 	case "search":
-		if len(os.Args) < 3 {
+		if len(args) < 2 {
 			fatal("usage: zapstore search <query>")
 		}
-		err = cmd.Search(os.Args[2])
+		return cmd.Search(client, args[1])
+
+	case "switch":
+		if len(args) < 3 {
+			fatal("usage: zapstore switch <app-id> <version>")
+		}
+		return cmd.Switch(args[1], args[2])
+
+	case "rollback":
+		if len(args) < 2 {
+			fatal("usage: zapstore rollback <app-id>")
+		}
+		return cmd.Rollback(args[1])
+
+	case "keep":
+		if len(args) < 2 {
+			fatal("usage: zapstore keep <app-id> [<n>]")
+		}
+		count := ""
+		if len(args) >= 3 {
+			count = args[2]
+		}
+		return cmd.Keep(args[1], count)
+
+	case "pin":
+		if len(args) < 2 {
+			fatal("usage: zapstore pin <app-id> [<constraint>]")
+		}
+		constraint := ""
+		if len(args) >= 3 {
+			constraint = args[2]
+		}
+		return cmd.Pin(args[1], constraint)
+
+	case "unpin":
+		if len(args) < 2 {
+			fatal("usage: zapstore unpin <app-id>")
+		}
+		return cmd.Unpin(args[1])
+
+	case "channel":
+		return cmd.Channel(args[1:])
 
 	case "cleanup":
-		err = cmd.Cleanup()
+		return cmd.Cleanup()
+
+	case "cache":
+		return cmd.Cache(args[1:])
+
+	case "trust":
+		if len(args) < 2 {
+			fatal("usage: zapstore trust <app-id>")
+		}
+		return cmd.Trust(client, args[1])
 
 	case "help", "--help", "-h":
 		fmt.Print(usage)
-		return
+		return nil
 
 	default:
-		fmt.Fprintf(os.Stderr, "%s unknown command: %s\n\n", ui.Cross(), os.Args[1])
+		if ui.JSON {
+			fatal("unknown command: " + args[0])
+		}
+		fmt.Fprintf(os.Stderr, "%s unknown command: %s\n\n", ui.Cross(), args[0])
 		fmt.Print(usage)
 		os.Exit(1)
+		return nil
 	}
+}
 
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "\n%s %v\n", ui.Cross(), err)
-		os.Exit(1)
+// exitStatus returns the status to exit with after err: 1, or
+// exitUpdatesAvailable for an *UpdatesAvailableError, which is not a
+// failure but which scripts need to tell apart from up to date.
+func exitStatus(err error) int {
+	var updates *cmd.UpdatesAvailableError
+	if errors.As(err, &updates) {
+		return exitUpdatesAvailable
+	}
+	return 1
+}
+
+// mutates reports whether a command changes installed packages, state or
+// the cache, and so must hold the state lock.
+func mutates(args []string) bool {
+	switch args[0] {
+	case "update":
+		return !slices.Contains(args, "--dry-run")
+	case "install", "remove", "cleanup", "trust", "switch", "rollback", "pin", "unpin":
+		return true
+	case "keep":
+		return len(args) > 2
+	case "channel":
+		return len(args) > 1
+	case "cache":
+		return len(args) > 1 && args[1] != "ls"
+	}
+	return false
+}
+
+// acquireLock takes the state lock. If another zapstore holds it, this
+// fails with its pid, or with wait blocks until it is released.
+func acquireLock(wait bool) (*store.Lock, error) {
+	lock, err := store.TryLock()
+	var locked *store.LockedError
+	if !wait || !errors.As(err, &locked) {
+		return lock, err
+	}
+
+	msg := "Waiting for another zapstore process to finish..."
+	if locked.PID != 0 {
+		msg = fmt.Sprintf("Waiting for another zapstore process %s to finish...", ui.Dim(fmt.Sprintf("(pid %d)", locked.PID)))
+	}
+	sp := ui.NewSpinner(msg)
+	sp.Start()
+	lock, err = store.WaitLock()
+	sp.Stop()
+	return lock, err
+}
+
+// extractFlag removes every occurrence of a boolean flag from args and
+// reports whether it was present.
+func extractFlag(args []string, name string) ([]string, bool) {
+	var rest []string
+	found := false
+	for _, a := range args {
+		if a == name {
+			found = true
+			continue
+		}
+		rest = append(rest, a)
 	}
+	return rest, found
 }
 
+// extractValue removes a flag that takes a value, given as "--name value"
+// or "--name=value", from args and returns the last value. A flag without
+// a value is reported as present with an empty one.
+func extractValue(args []string, name string) ([]string, string, bool) {
+	var rest []string
+	value, found := "", false
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if v, ok := strings.CutPrefix(a, name+"="); ok {
+			value, found = v, true
+			continue
+		}
+		if a == name {
+			value, found = "", true
+			if i+1 < len(args) {
+				value = args[i+1]
+				i++
+			}
+			continue
+		}
+		rest = append(rest, a)
+	}
+	return rest, value, found
+}
+
+// fatal reports a usage error and exits.
 func fatal(msg string) {
-	fmt.Fprintln(os.Stderr, msg)
+	if ui.JSON {
+		ui.PrintJSON(map[string]any{"error": &cmd.JSONError{Code: "usage", Message: msg}})
+	} else {
+		fmt.Fprintln(os.Stderr, msg)
+	}
 	os.Exit(1)
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/zapstore/zapstore/cmd"
+	"github.com/zapstore/zapstore/nostr"
+	"github.com/zapstore/zapstore/ui"
+)
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{&cmd.UpdatesAvailableError{Count: 2}, exitUpdatesAvailable},
+		{fmt.Errorf("outdated: %w", &cmd.UpdatesAvailableError{Count: 1}), exitUpdatesAvailable},
+		{&nostr.AppNotFoundError{AppID: "com.example.tool"}, 1},
+		{&nostr.ReleaseNotFoundError{AppID: "com.example.tool", Constraint: "^2"}, 1},
+		{errors.New("connecting to relay: timeout"), 1},
+	}
+	for _, tt := range tests {
+		if got := exitStatus(tt.err); got != tt.want {
+			t.Errorf("exitStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+// failCases are the errors TestFail hands to fail in a child process.
+var failCases = map[string]error{
+	"updates":       fmt.Errorf("outdated: %w", &cmd.UpdatesAvailableError{Count: 2}),
+	"app_not_found": &nostr.AppNotFoundError{AppID: "com.example.tool"},
+	"error":         errors.New("connecting to relay: timeout"),
+}
+
+func TestFail(t *testing.T) {
+	// In the child, fail exits the process with the status under test
+	if name := os.Getenv("ZAPSTORE_TEST_FAIL"); name != "" {
+		ui.JSON = os.Getenv("ZAPSTORE_TEST_JSON") != ""
+		fail(failCases[name])
+		return
+	}
+
+	tests := []struct {
+		name   string
+		json   bool
+		status int
+		code   string // the JSON error code, or what stderr must contain
+	}{
+		{"updates", false, 2, "2 package(s) can be updated"},
+		{"updates", true, 2, "updates_available"},
+		{"app_not_found", true, 1, "app_not_found"},
+		{"error", false, 1, "connecting to relay"},
+		{"error", true, 1, "error"},
+	}
+	for _, tt := range tests {
+		c := exec.Command(os.Args[0], "-test.run=^TestFail$")
+		c.Env = append(os.Environ(), "ZAPSTORE_TEST_FAIL="+tt.name)
+		if tt.json {
+			c.Env = append(c.Env, "ZAPSTORE_TEST_JSON=1")
+		}
+		var stdout, stderr bytes.Buffer
+		c.Stdout, c.Stderr = &stdout, &stderr
+		err := c.Run()
+
+		var exit *exec.ExitError
+		if !errors.As(err, &exit) {
+			t.Fatalf("%s: child did not exit with a status: %v", tt.name, err)
+		}
+		if exit.ExitCode() != tt.status {
+			t.Errorf("%s (json %v) exited %d, want %d", tt.name, tt.json, exit.ExitCode(), tt.status)
+		}
+		if !tt.json {
+			if !strings.Contains(stderr.String(), tt.code) {
+				t.Errorf("%s printed %q, want it to contain %q", tt.name, stderr.String(), tt.code)
+			}
+			continue
+		}
+		var doc struct {
+			Error cmd.JSONError `json:"error"`
+		}
+		if err := json.Unmarshal(stdout.Bytes(), &doc); err != nil {
+			t.Errorf("%s: stdout %q is not a JSON error: %v", tt.name, stdout.String(), err)
+		} else if doc.Error.Code != tt.code {
+			t.Errorf("%s: JSON error code %q, want %q", tt.name, doc.Error.Code, tt.code)
+		}
+	}
+}
+
+func TestMutates(t *testing.T) {
+	tests := map[string]bool{
+		"update":                            true,
+		"update com.example.tool":           true,
+		"update --dry-run":                  false,
+		"update com.example.tool --dry-run": false,
+		"outdated":                          false,
+		"install com.example.tool":          true,
+		"pin com.example.tool":              true,
+		"list":                              false,
+		"keep com.example.tool":             false,
+		"keep com.example.tool 3":           true,
+		"cache ls":                          false,
+		"cache prune":                       true,
+	}
+	for cmdline, want := range tests {
+		if got := mutates(strings.Fields(cmdline)); got != want {
+			t.Errorf("mutates(%q) = %v, want %v", cmdline, got, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package nostr
+
+import (
+	"context"
+	"slices"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/zapstore/zapstore/platform"
+)
+
+// ResolveRequest is one app for ResolveAll to resolve.
+type ResolveRequest struct {
+	AppID string
+	// Constraint selects the release as for Resolve; "" means the latest
+	// release on Channel.
+	Constraint string
+	Channel    string
+	// Publisher is the key pinned for the app, or "" if there is none; its
+	// app event is preferred as in ResolveApp.
+	Publisher string
+}
+
+// Resolution is the outcome of one ResolveRequest: what Resolve would have
+// returned for the app. When a step failed, Err is set and the fields
+// resolved before it are kept.
+type Resolution struct {
+	App     *AppInfo
+	Release *ReleaseInfo
+	Asset   *AssetInfo
+	Err     error
+}
+
+// ResolveAll resolves many apps as Resolve does, but with three queries in
+// total rather than three per app: the app events of every request, then
+// the releases of all apps found, then the assets of every selected
+// release. Results are keyed by app ID. A query that fails is reported in
+// the Err of each app it left unresolved.
+func (c *Client) ResolveAll(ctx context.Context, reqs []ResolveRequest, plat platform.Info) map[string]*Resolution {
+	out := make(map[string]*Resolution, len(reqs))
+	var appIDs, pinnedIDs, publishers []string
+	for _, r := range reqs {
+		out[r.AppID] = &Resolution{}
+		appIDs = append(appIDs, r.AppID)
+		if r.Publisher != "" {
+			pinnedIDs = append(pinnedIDs, r.AppID)
+			if !slices.Contains(publishers, r.Publisher) {
+				publishers = append(publishers, r.Publisher)
+			}
+		}
+	}
+	if len(reqs) == 0 {
+		return out
+	}
+
+	// 1. Apps, asking for the pinned publishers' events by name so newer
+	// ones by other authors cannot crowd them out
+	filters := nostr.Filters{{
+		Kinds: []int{KindApp},
+		Tags: nostr.TagMap{
+			"d": appIDs,
+			"f": []string{plat.Platform},
+		},
+	}}
+	if len(publishers) > 0 {
+		filters = append(filters, nostr.Filter{
+			Kinds:   []int{KindApp},
+			Authors: publishers,
+			Tags: nostr.TagMap{
+				"d": pinnedIDs,
+				"f": []string{plat.Platform},
+			},
+		})
+	}
+	events, err := c.QueryEvents(ctx, filters)
+	var apps []*AppInfo
+	for _, r := range reqs {
+		res := out[r.AppID]
+		if err != nil {
+			res.Err = err
+			continue
+		}
+		if res.App, res.Err = pickApp(events, r.AppID, r.Publisher); res.Err == nil {
+			apps = append(apps, res.App)
+		}
+	}
+	if len(apps) == 0 {
+		return out
+	}
+
+	// 2. Releases
+	events, err = c.QueryEvents(ctx, releaseFilters(apps))
+	var assetIDs, authors []string
+	for _, r := range reqs {
+		res := out[r.AppID]
+		if res.Err != nil {
+			continue // failed an earlier step
+		}
+		if err != nil {
+			res.Err = err
+			continue
+		}
+		releases, rerr := appReleases(res.App, ownReleases(res.App, events))
+		if rerr == nil {
+			res.Release, rerr = selectRelease(res.App, releases, r.Constraint, r.Channel)
+		}
+		if rerr == nil && len(res.Release.AssetEventIDs) == 0 {
+			rerr = errNoAssetRefs
+		}
+		if rerr != nil {
+			res.Err = rerr
+			continue
+		}
+		assetIDs = append(assetIDs, res.Release.AssetEventIDs...)
+		if !slices.Contains(authors, res.App.Pubkey) {
+			authors = append(authors, res.App.Pubkey)
+		}
+	}
+	if len(assetIDs) == 0 {
+		return out
+	}
+
+	// 3. Assets, filtered to our platform's f tag
+	events, err = c.QueryEvents(ctx, nostr.Filters{{
+		IDs:     assetIDs,
+		Authors: authors,
+		Tags:    nostr.TagMap{"f": []string{plat.Platform}},
+	}})
+	for _, r := range reqs {
+		res := out[r.AppID]
+		if res.Err != nil {
+			continue // failed an earlier step
+		}
+		if err != nil {
+			res.Err = err
+			continue
+		}
+		assets, aerr := matchAssets(res.App, res.Release, events, plat)
+		if aerr != nil {
+			res.Err = aerr
+			continue
+		}
+		res.Asset = assets[0]
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/zapstore/zapstore/platform"
+)
+
+// publisher signs the app, release and asset events of one app.
+type publisher struct {
+	t     *testing.T
+	sk    string
+	appID string
+}
+
+func (p publisher) sign(kind int, tags nostr.Tags) *nostr.Event {
+	p.t.Helper()
+	ev := &nostr.Event{Kind: kind, CreatedAt: nostr.Now(), Tags: tags}
+	if err := ev.Sign(p.sk); err != nil {
+		p.t.Fatal(err)
+	}
+	return ev
+}
+
+// release returns a release of ver with one asset for plat, its asset
+// hash being hash.
+func (p publisher) release(ver, hash string, plat platform.Info) []*nostr.Event {
+	asset := p.sign(KindAsset, nostr.Tags{
+		{"f", plat.Platform}, {"x", hash}, {"url", "https://example.invalid/" + hash},
+	})
+	rel := p.sign(KindRelease, nostr.Tags{
+		{"d", p.appID + "@" + ver}, {"i", p.appID}, {"version", ver}, {"e", asset.ID},
+	})
+	return []*nostr.Event{asset, rel}
+}
+
+func TestResolveAllOffline(t *testing.T) {
+	plat := platform.Detect()
+	tool := publisher{t, nostr.GeneratePrivateKey(), "com.example.tool"}
+	other := publisher{t, nostr.GeneratePrivateKey(), "com.example.other"}
+	old := publisher{t, nostr.GeneratePrivateKey(), "com.example.old"}
+
+	var events []*nostr.Event
+	for _, p := range []publisher{tool, other, old} {
+		events = append(events, p.sign(KindApp, nostr.Tags{{"d", p.appID}, {"f", plat.Platform}}))
+	}
+	events = append(events, tool.release("1.0.0", "aa01", plat)...)
+	events = append(events, tool.release("1.1.0", "aa02", plat)...)
+	events = append(events, other.release("2.0.0", "bb01", plat)...)
+	events = append(events, other.release("3.0.0", "bb02", plat)...)
+	events = append(events, old.release("1.0.0", "cc01", plat)...)
+
+	useCache(t, events...)
+	Offline = true
+	defer func() { Offline = false }()
+
+	got := NewClient(nil).ResolveAll(context.Background(), []ResolveRequest{
+		{AppID: "com.example.tool"},
+		{AppID: "com.example.other", Constraint: "^2"},
+		{AppID: "com.example.old", Constraint: "^2"},
+		{AppID: "com.example.missing"},
+	}, plat)
+
+	// Each app gets its own release and asset, not another app's
+	resolved := []struct{ appID, version, hash string }{
+		{"com.example.tool", "1.1.0", "aa02"},
+		{"com.example.other", "2.0.0", "bb01"},
+	}
+	for _, want := range resolved {
+		res := got[want.appID]
+		if res == nil || res.Err != nil {
+			t.Errorf("%s: %+v", want.appID, res)
+			continue
+		}
+		if res.App.AppID != want.appID || res.Release.Version != want.version || res.Asset.Hash != want.hash {
+			t.Errorf("%s resolved to %s v%s asset %s, want v%s asset %s",
+				want.appID, res.App.AppID, res.Release.Version, res.Asset.Hash, want.version, want.hash)
+		}
+	}
+
+	var relNotFound *ReleaseNotFoundError
+	if res := got["com.example.old"]; !errors.As(res.Err, &relNotFound) {
+		t.Errorf("com.example.old: %v, want *ReleaseNotFoundError", res.Err)
+	} else if res.App == nil || res.Release != nil {
+		t.Errorf("com.example.old keeps app %v and release %v, want only the app", res.App, res.Release)
+	}
+	var appNotFound *AppNotFoundError
+	if res := got["com.example.missing"]; !errors.As(res.Err, &appNotFound) {
+		t.Errorf("com.example.missing: %v, want *AppNotFoundError", res.Err)
+	} else if !appNotFound.Offline {
+		t.Error("AppNotFoundError does not say the lookup was offline")
+	}
+	if len(got) != 4 {
+		t.Errorf("ResolveAll returned %d resolutions, want one per request", len(got))
+	}
+}
@@ -0,0 +1,171 @@
+package nostr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/zapstore/zapstore/store"
+)
+
+// Offline makes QueryEvents answer from the local event cache instead of
+// the relays. Every online query adds its results to the cache.
+var Offline = false
+
+// eventCache holds every event fetched from relays, following NIP-01:
+// regular events are kept by ID, replaceable and addressable ones once per
+// coordinate with the newest winning. It is loaded once per process and
+// written back after each query that changes it.
+type eventCache struct {
+	mu     sync.Mutex
+	path   string
+	events map[string]*nostr.Event // by cacheKey
+}
+
+var (
+	cache     *eventCache
+	cacheErr  error
+	cacheOnce sync.Once
+)
+
+// openCache returns the process-wide event cache, loading it on first use.
+func openCache() (*eventCache, error) {
+	cacheOnce.Do(func() {
+		path, err := store.EventsPath()
+		if err != nil {
+			cacheErr = err
+			return
+		}
+		events, err := readCache(path)
+		if err != nil {
+			cacheErr = err
+			return
+		}
+		cache = &eventCache{path: path, events: make(map[string]*nostr.Event)}
+		cache.merge(events)
+	})
+	return cache, cacheErr
+}
+
+// readCache reads the events stored at path. A missing file is an empty
+// cache.
+func readCache(path string) ([]*nostr.Event, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading event cache: %w", err)
+	}
+	var events []*nostr.Event
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("parsing event cache: %w", err)
+	}
+	return events, nil
+}
+
+// cacheKey identifies the slot an event occupies in the cache.
+func cacheKey(ev *nostr.Event) string {
+	if isReplaceable(ev.Kind) {
+		return address(ev)
+	}
+	return ev.ID
+}
+
+// merge adds events, keeping only the newest per coordinate, and reports
+// whether anything changed.
+func (c *eventCache) merge(events []*nostr.Event) bool {
+	changed := false
+	for _, ev := range events {
+		key := cacheKey(ev)
+		if cur, ok := c.events[key]; ok && (cur.ID == ev.ID || !newer(ev, cur)) {
+			continue
+		}
+		c.events[key] = ev
+		changed = true
+	}
+	return changed
+}
+
+// add stores events fetched from relays and writes the cache back if they
+// changed it.
+func (c *eventCache) add(events []*nostr.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.merge(events) {
+		return nil
+	}
+
+	// Another zapstore may have written since we loaded; keep its events
+	disk, err := readCache(c.path)
+	if err == nil {
+		c.merge(disk)
+	}
+
+	all := make([]*nostr.Event, 0, len(c.events))
+	for _, ev := range c.events {
+		all = append(all, ev)
+	}
+	sort.Slice(all, func(i, j int) bool { return cacheKey(all[i]) < cacheKey(all[j]) })
+
+	data, err := json.Marshal(all)
+	if err != nil {
+		return fmt.Errorf("encoding event cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("creating cache directory: %w", err)
+	}
+	if err := store.WriteFileAtomic(c.path, data, 0o644); err != nil {
+		return fmt.Errorf("writing event cache: %w", err)
+	}
+	return nil
+}
+
+// query returns the cached events matching any of filters, newest first.
+// Each filter's limit applies to its own matches, as on a relay.
+func (c *eventCache) query(filters nostr.Filters) []*nostr.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	all := make([]*nostr.Event, 0, len(c.events))
+	for _, ev := range c.events {
+		all = append(all, ev)
+	}
+	sort.Slice(all, func(i, j int) bool { return newer(all[i], all[j]) })
+
+	var out []*nostr.Event
+	for _, f := range filters {
+		n := 0
+		for _, ev := range all {
+			if f.Limit > 0 && n == f.Limit {
+				break
+			}
+			if f.Matches(ev) && searchMatches(f.Search, ev) {
+				out = append(out, ev)
+				n++
+			}
+		}
+	}
+	return out
+}
+
+// searchMatches approximates a NIP-50 search locally: every word of query
+// must appear, case-insensitively, in the event's content or its d, name
+// or summary tag.
+func searchMatches(query string, ev *nostr.Event) bool {
+	text := strings.ToLower(strings.Join([]string{
+		ev.Content, tagValue(ev, "d"), tagValue(ev, "name"), tagValue(ev, "summary"),
+	}, " "))
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
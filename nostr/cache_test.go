@@ -0,0 +1,82 @@
+package nostr
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// useCache points the process-wide event cache at a fresh cache directory
+// holding events, and reloads it from there on next use.
+func useCache(t *testing.T, events ...*nostr.Event) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	reset := func() { cache, cacheErr, cacheOnce = nil, nil, sync.Once{} }
+	reset()
+	t.Cleanup(reset)
+	c, err := openCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.add(events); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEventCacheReplaceable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+	c := &eventCache{path: path, events: make(map[string]*nostr.Event)}
+
+	sk := nostr.GeneratePrivateKey()
+	release := func(version string, at nostr.Timestamp) *nostr.Event {
+		ev := &nostr.Event{Kind: KindRelease, CreatedAt: at, Tags: nostr.Tags{{"d", "app@1"}, {"version", version}}}
+		if err := ev.Sign(sk); err != nil {
+			t.Fatal(err)
+		}
+		return ev
+	}
+	newer, older := release("1.0.1", 200), release("1.0.0", 100)
+	asset := signedEvent(t, KindAsset, nostr.Tags{{"x", "abc"}})
+
+	if err := c.add([]*nostr.Event{newer, asset}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.add([]*nostr.Event{older}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh load sees what was written, with the older release replaced
+	events, err := readCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded := &eventCache{path: path, events: make(map[string]*nostr.Event)}
+	reloaded.merge(events)
+
+	got := reloaded.query(nostr.Filters{{Kinds: []int{KindRelease}}})
+	if len(got) != 1 || got[0].ID != newer.ID {
+		t.Errorf("releases = %v, want only the newest", got)
+	}
+	if got := reloaded.query(nostr.Filters{{IDs: []string{asset.ID}}}); len(got) != 1 {
+		t.Errorf("asset not cached: %v", got)
+	}
+}
+
+func TestEventCacheSearch(t *testing.T) {
+	c := &eventCache{events: make(map[string]*nostr.Event)}
+	jq := signedEvent(t, KindApp, nostr.Tags{{"d", "com.github.jqlang.jq"}, {"name", "jq"}, {"summary", "Command-line JSON processor"}})
+	rg := signedEvent(t, KindApp, nostr.Tags{{"d", "com.github.burntsushi.ripgrep"}, {"name", "ripgrep"}})
+	c.merge([]*nostr.Event{jq, rg})
+
+	tests := map[string]int{"json": 1, "JQ processor": 1, "ripgrep": 1, "github": 2, "json ripgrep": 0, "": 2}
+	for query, want := range tests {
+		if got := c.query(nostr.Filters{{Kinds: []int{KindApp}, Search: query}}); len(got) != want {
+			t.Errorf("search %q found %d, want %d", query, len(got), want)
+		}
+	}
+	if got := c.query(nostr.Filters{{Kinds: []int{KindApp}, Limit: 1}}); len(got) != 1 {
+		t.Errorf("limit 1 returned %d events", len(got))
+	}
+}
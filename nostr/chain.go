@@ -0,0 +1,65 @@
+package nostr
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// ChainError reports a broken app → release → asset chain: an event that
+// was not signed by the app's publisher, or a release that does not point
+// back at the app it claims to belong to.
+type ChainError struct {
+	AppID   string
+	Kind    int
+	EventID string
+	Reason  string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("untrusted metadata for %q: kind %d event %s %s",
+		e.AppID, e.Kind, shortID(e.EventID), e.Reason)
+}
+
+// appAddress returns the `a` tag coordinate of an app event.
+func appAddress(app *AppInfo) string {
+	return fmt.Sprintf("%d:%s:%s", KindApp, app.Pubkey, app.AppID)
+}
+
+// checkRelease verifies that a release event was signed by the app's
+// publisher and references the app through its `i` or `a` tag.
+func checkRelease(app *AppInfo, ev *nostr.Event) error {
+	if ev.PubKey != app.Pubkey {
+		return &ChainError{AppID: app.AppID, Kind: ev.Kind, EventID: ev.ID,
+			Reason: "is not signed by the app publisher"}
+	}
+	if !hasTag(ev, "i", app.AppID) && !hasTag(ev, "a", appAddress(app)) {
+		return &ChainError{AppID: app.AppID, Kind: ev.Kind, EventID: ev.ID,
+			Reason: "does not reference the app"}
+	}
+	return nil
+}
+
+// checkAsset verifies that an asset event was signed by the app's publisher
+// and is one of the assets the release points at.
+func checkAsset(app *AppInfo, release *ReleaseInfo, ev *nostr.Event) error {
+	if !slices.Contains(release.AssetEventIDs, ev.ID) {
+		return &ChainError{AppID: app.AppID, Kind: ev.Kind, EventID: ev.ID,
+			Reason: "is not referenced by the release"}
+	}
+	if ev.PubKey != app.Pubkey {
+		return &ChainError{AppID: app.AppID, Kind: ev.Kind, EventID: ev.ID,
+			Reason: "is not signed by the app publisher"}
+	}
+	return nil
+}
+
+func hasTag(ev *nostr.Event, key, value string) bool {
+	for _, tag := range ev.Tags {
+		if len(tag) >= 2 && tag[0] == key && tag[1] == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package nostr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestCheckRelease(t *testing.T) {
+	app := &AppInfo{AppID: "com.example.tool", Pubkey: "publisher"}
+
+	tests := []struct {
+		name string
+		ev   *nostr.Event
+		ok   bool
+	}{
+		{"i tag", &nostr.Event{PubKey: "publisher", Tags: nostr.Tags{{"i", "com.example.tool"}}}, true},
+		{"a tag", &nostr.Event{PubKey: "publisher", Tags: nostr.Tags{{"a", "32267:publisher:com.example.tool"}}}, true},
+		{"other author", &nostr.Event{PubKey: "mallory", Tags: nostr.Tags{{"i", "com.example.tool"}}}, false},
+		{"other app", &nostr.Event{PubKey: "publisher", Tags: nostr.Tags{{"i", "com.example.other"}}}, false},
+		{"a tag other author", &nostr.Event{PubKey: "publisher", Tags: nostr.Tags{{"a", "32267:mallory:com.example.tool"}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRelease(app, tt.ev)
+			if tt.ok && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			var chainErr *ChainError
+			if !tt.ok && !errors.As(err, &chainErr) {
+				t.Errorf("expected *ChainError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckAsset(t *testing.T) {
+	app := &AppInfo{AppID: "com.example.tool", Pubkey: "publisher"}
+	release := &ReleaseInfo{AssetEventIDs: []string{"asset1"}}
+
+	if err := checkAsset(app, release, &nostr.Event{ID: "asset1", PubKey: "publisher"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := checkAsset(app, release, &nostr.Event{ID: "asset1", PubKey: "mallory"}); err == nil {
+		t.Error("expected error for asset signed by another key")
+	}
+	if err := checkAsset(app, release, &nostr.Event{ID: "asset2", PubKey: "publisher"}); err == nil {
+		t.Error("expected error for asset not referenced by release")
+	}
+}
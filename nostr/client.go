@@ -0,0 +1,123 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// redialDelay is how long a relay that could not be reached is skipped
+// before the next attempt, so one that times out does not stall every
+// query of a command.
+const redialDelay = 30 * time.Second
+
+// ErrClientClosed is returned by queries on a Client after Close.
+var ErrClientClosed = errors.New("relay client is closed")
+
+// Client queries a fixed set of relays, keeping one connection to each open
+// across queries so a resolve chain, or an update of many packages, does
+// not reconnect for every step. Idle connections are kept alive by pings
+// and one found closed is redialed on next use. A Client is safe for
+// concurrent use; Close it when done.
+type Client struct {
+	relayURLs []string
+
+	mu     sync.Mutex
+	conns  map[string]*conn // by normalized URL
+	closed bool
+}
+
+// conn is a pooled relay connection. Queries on it run one at a time, since
+// a negentropy reconciliation needs the connection to itself.
+type conn struct {
+	mu       sync.Mutex
+	relay    *nostr.Relay
+	neg      *negotiator
+	dialErr  error
+	failedAt time.Time
+}
+
+// NewClient returns a Client for relayURLs. No connection is made until
+// the first query.
+func NewClient(relayURLs []string) *Client {
+	return &Client{
+		relayURLs: dedupeURLs(relayURLs),
+		conns:     make(map[string]*conn),
+	}
+}
+
+// Relays returns the relays the client queries.
+func (c *Client) Relays() []string {
+	return c.relayURLs
+}
+
+// acquire returns the connection to relayURL, dialing it if there is none
+// yet or it has dropped. The connection is returned locked; release it
+// with cn.mu.Unlock.
+func (c *Client) acquire(ctx context.Context, relayURL string) (*conn, error) {
+	key := nostr.NormalizeURL(relayURL)
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClientClosed
+	}
+	cn, ok := c.conns[key]
+	if !ok {
+		cn = &conn{}
+		c.conns[key] = cn
+	}
+	c.mu.Unlock()
+
+	cn.mu.Lock()
+	if cn.relay != nil && cn.relay.IsConnected() {
+		return cn, nil
+	}
+	if cn.dialErr != nil && time.Since(cn.failedAt) < redialDelay {
+		err := cn.dialErr
+		cn.mu.Unlock()
+		return nil, err
+	}
+
+	neg := &negotiator{}
+	relay, err := nostr.RelayConnect(ctx, relayURL,
+		nostr.WithCustomHandler(neg.handle), nostr.WithNoticeHandler(neg.notice))
+	if err != nil {
+		cn.relay, cn.neg = nil, nil
+		cn.dialErr = fmt.Errorf("connecting to relay %s: %w", relayURL, err)
+		cn.failedAt = time.Now()
+		err := cn.dialErr
+		cn.mu.Unlock()
+		return nil, err
+	}
+	cn.relay, cn.neg, cn.dialErr = relay, neg, nil
+	return cn, nil
+}
+
+// Close closes every connection, waiting for queries in progress on them
+// to finish. Later queries fail with ErrClientClosed.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	conns := c.conns
+	c.conns = nil
+	c.mu.Unlock()
+
+	for _, cn := range conns {
+		cn.mu.Lock()
+		if cn.relay != nil {
+			cn.relay.Close()
+			cn.relay = nil
+		}
+		cn.mu.Unlock()
+	}
+	return nil
+}
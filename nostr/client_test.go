@@ -0,0 +1,35 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestNewClientDedupesRelays(t *testing.T) {
+	c := NewClient([]string{"wss://relay.example.com", "wss://relay.example.com/", "wss://other.example.com"})
+	defer c.Close()
+
+	want := []string{"wss://relay.example.com", "wss://other.example.com"}
+	if got := c.Relays(); !slices.Equal(got, want) {
+		t.Errorf("Relays() = %v, want %v", got, want)
+	}
+}
+
+func TestClientClosed(t *testing.T) {
+	c := NewClient([]string{"wss://relay.example.com"})
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+
+	_, err := c.QueryEvents(context.Background(), nostr.Filters{{Kinds: []int{KindApp}}})
+	if !errors.Is(err, ErrClientClosed) {
+		t.Errorf("QueryEvents after Close = %v, want ErrClientClosed", err)
+	}
+}
@@ -0,0 +1,323 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip11"
+	"github.com/nbd-wtf/go-nostr/nip77"
+	"github.com/nbd-wtf/go-nostr/nip77/negentropy"
+	"github.com/nbd-wtf/go-nostr/nip77/negentropy/storage/vector"
+)
+
+// syncKinds are the kinds refreshed with NIP-77 negentropy reconciliation
+// instead of a plain REQ when the relay supports it.
+var syncKinds = []int{KindApp, KindRelease, KindAsset}
+
+const (
+	// negTimeout bounds a reconciliation, so a relay that ignores NEG-OPEN
+	// costs a few seconds before falling back to REQ.
+	negTimeout = 10 * time.Second
+
+	// fetchBatch is the number of IDs requested per filter when fetching
+	// the events a reconciliation found missing.
+	fetchBatch = 100
+)
+
+// negSupport remembers, per relay URL, whether negentropy can be used. A
+// relay that advertises NIP-77 but fails a reconciliation is not tried
+// again in this process.
+var negSupport sync.Map // normalized URL → bool
+
+// supportsNegentropy reports whether relayURL lists NIP-77 in its NIP-11
+// information document.
+func supportsNegentropy(ctx context.Context, relayURL string) bool {
+	key := nostr.NormalizeURL(relayURL)
+	if ok, found := negSupport.Load(key); found {
+		return ok.(bool)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	info, err := nip11.Fetch(ctx, relayURL)
+	ok := err == nil && supportsNIP(info.SupportedNIPs, 77)
+	negSupport.Store(key, ok)
+	return ok
+}
+
+// supportsNIP reports whether a NIP-11 supported_nips list contains nip.
+// Relays list numbers, but some send them as strings.
+func supportsNIP(nips []any, nip int) bool {
+	for _, n := range nips {
+		switch v := n.(type) {
+		case float64:
+			if v == float64(nip) {
+				return true
+			}
+		case int:
+			if v == nip {
+				return true
+			}
+		case json.Number:
+			if v.String() == strconv.Itoa(nip) {
+				return true
+			}
+		case string:
+			if v == strconv.Itoa(nip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// syncable reports whether filter can be answered by reconciliation: it
+// selects only synced kinds and does not rely on NIP-50 search, which
+// negentropy cannot express.
+func syncable(filter nostr.Filter) bool {
+	if filter.Search != "" || len(filter.Kinds) == 0 {
+		return false
+	}
+	for _, k := range filter.Kinds {
+		if !slices.Contains(syncKinds, k) {
+			return false
+		}
+	}
+	return true
+}
+
+// negotiator routes NIP-77 messages arriving on a relay connection to the
+// reconciliation in progress on it. A connection runs one at a time.
+type negotiator struct {
+	mu  sync.Mutex // held while a message is reconciled, so cur can be torn down safely
+	cur *reconciliation
+}
+
+// reconciliation is a single NEG-OPEN session.
+type reconciliation struct {
+	id    string
+	write func([]byte) <-chan error // the relay connection's Write
+	neg   *negentropy.Negentropy
+	done  chan error
+	once  sync.Once
+}
+
+func (r *reconciliation) finish(err error) {
+	r.once.Do(func() { r.done <- err })
+}
+
+// handle is the relay's custom handler, receiving messages go-nostr does
+// not parse itself.
+func (n *negotiator) handle(data string) {
+	n.mu.Lock()
+	r := n.cur
+	if r == nil {
+		n.mu.Unlock()
+		return
+	}
+
+	var next string
+	switch env := nip77.ParseNegMessage(data).(type) {
+	case *nip77.MessageEnvelope:
+		if env.SubscriptionID != r.id {
+			break
+		}
+		var err error
+		if next, err = r.neg.Reconcile(env.Message); err != nil {
+			r.finish(fmt.Errorf("reconciling: %w", err))
+		} else if next == "" {
+			r.finish(nil)
+		}
+	case *nip77.ErrorEnvelope:
+		if env.SubscriptionID == r.id {
+			r.finish(fmt.Errorf("relay refused reconciliation: %s", env.Reason))
+		}
+	}
+	n.mu.Unlock()
+
+	if next != "" {
+		msg, _ := nip77.MessageEnvelope{SubscriptionID: r.id, Message: next}.MarshalJSON()
+		send(r.write, msg)
+	}
+}
+
+// send writes msg without waiting for it to go out. The answer is still
+// read, since the relay's writer blocks until a failed write is reported.
+func send(write func([]byte) <-chan error, msg []byte) {
+	answer := write(msg)
+	go func() {
+		for range answer {
+		}
+	}()
+}
+
+// notice fails the reconciliation in progress: relays without NIP-77
+// answer NEG-OPEN with a NOTICE. Notices outside one are dropped.
+func (n *negotiator) notice(msg string) {
+	n.mu.Lock()
+	r := n.cur
+	n.mu.Unlock()
+	if r != nil {
+		r.finish(fmt.Errorf("relay notice: %s", msg))
+	}
+}
+
+// reconcile runs NIP-77 for filter over a relay connection written to with
+// write, with local as our side of the set. It returns the IDs only we have
+// and the IDs only the relay has.
+func (n *negotiator) reconcile(ctx context.Context, write func([]byte) <-chan error, filter nostr.Filter, local []*nostr.Event) (haves, haveNots []string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, negTimeout)
+	defer cancel()
+
+	vec := vector.New()
+	for _, ev := range local {
+		vec.Insert(ev.CreatedAt, ev.ID)
+	}
+	vec.Seal()
+
+	r := &reconciliation{
+		id:    "zapstore-sync",
+		write: write,
+		neg:   negentropy.New(vec, 1024*1024),
+		done:  make(chan error, 1),
+	}
+
+	// The ID channels are small; drain them while the relay reader feeds
+	// them. Reconcile closes them only on success, so stop ends the drains
+	// on every other return.
+	var (
+		ours, theirs []string
+		wg           sync.WaitGroup
+		stop         = make(chan struct{})
+	)
+	drain := func(ids <-chan string, into *[]string) {
+		defer wg.Done()
+		for {
+			select {
+			case id, ok := <-ids:
+				if !ok {
+					return
+				}
+				*into = append(*into, id)
+			case <-stop:
+				return
+			}
+		}
+	}
+	wg.Add(2)
+	go drain(r.neg.Haves, &ours)
+	go drain(r.neg.HaveNots, &theirs)
+	defer func() {
+		// Once cur is cleared under the lock no message is being
+		// reconciled, so nothing feeds the channels any more
+		n.mu.Lock()
+		n.cur = nil
+		n.mu.Unlock()
+		close(stop)
+		wg.Wait()
+	}()
+
+	n.mu.Lock()
+	n.cur = r
+	n.mu.Unlock()
+
+	open, _ := nip77.OpenEnvelope{SubscriptionID: r.id, Filter: filter, Message: r.neg.Start()}.MarshalJSON()
+	if err := <-write(open); err != nil {
+		return nil, nil, fmt.Errorf("writing to relay: %w", err)
+	}
+	defer func() {
+		msg, _ := nip77.CloseEnvelope{SubscriptionID: r.id}.MarshalJSON()
+		send(write, msg)
+	}()
+
+	select {
+	case err := <-r.done:
+		if err != nil {
+			return nil, nil, err
+		}
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+
+	// Reconcile closes both channels before reporting completion
+	wg.Wait()
+	return ours, theirs, nil
+}
+
+// syncFilter answers filter on relay by reconciling it against the events
+// already cached, fetching only those the cache lacks. ok is false when the
+// filter or relay does not allow it, there is nothing cached to reconcile
+// against (a REQ is cheaper then), or the reconciliation failed; the caller
+// falls back to a plain REQ.
+func syncFilter(ctx context.Context, relay *nostr.Relay, n *negotiator, filter nostr.Filter) (events []*nostr.Event, ok bool) {
+	if !syncable(filter) {
+		return nil, false
+	}
+	c, err := openCache()
+	if err != nil {
+		return nil, false
+	}
+
+	// Reconcile the whole matching set; the limit is applied afterwards
+	all := filter
+	all.Limit = 0
+	local := c.query(nostr.Filters{all})
+	if len(local) == 0 {
+		return nil, false
+	}
+
+	// Only now is the relay asked whether it speaks NIP-77
+	if !supportsNegentropy(ctx, relay.URL) {
+		return nil, false
+	}
+	haves, haveNots, err := n.reconcile(ctx, relay.Write, all, local)
+	if err != nil {
+		negSupport.Store(nostr.NormalizeURL(relay.URL), false)
+		return nil, false
+	}
+
+	var fetched []*nostr.Event
+	for batch := range slices.Chunk(haveNots, fetchBatch) {
+		evs, err := relay.QuerySync(ctx, nostr.Filter{IDs: batch})
+		if err != nil {
+			return nil, false
+		}
+		fetched = append(fetched, evs...)
+	}
+
+	return reconciled(filter, local, haves, fetched), true
+}
+
+// reconciled assembles what the relay would have answered to filter: the
+// local events it also has plus those fetched from it, newest first and cut
+// to the filter's limit. Fetched events that do not match are dropped.
+func reconciled(filter nostr.Filter, local []*nostr.Event, haves []string, fetched []*nostr.Event) []*nostr.Event {
+	onlyLocal := make(map[string]bool, len(haves))
+	for _, id := range haves {
+		onlyLocal[id] = true
+	}
+
+	var out []*nostr.Event
+	for _, ev := range local {
+		if !onlyLocal[ev.ID] {
+			out = append(out, ev)
+		}
+	}
+	for _, ev := range fetched {
+		if filter.Matches(ev) {
+			out = append(out, ev)
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return newer(out[i], out[j]) })
+	if filter.Limit > 0 && len(out) > filter.Limit {
+		out = out[:filter.Limit]
+	}
+	return out
+}
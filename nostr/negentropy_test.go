@@ -0,0 +1,252 @@
+package nostr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip77"
+	"github.com/nbd-wtf/go-nostr/nip77/negentropy"
+	"github.com/nbd-wtf/go-nostr/nip77/negentropy/storage/vector"
+)
+
+func TestSupportsNIP(t *testing.T) {
+	tests := []struct {
+		nips []any
+		want bool
+	}{
+		{[]any{float64(1), float64(11), float64(77)}, true},
+		{[]any{"1", "77"}, true},
+		{[]any{json.Number("77")}, true},
+		{[]any{77}, true},
+		{[]any{float64(1), float64(50)}, false},
+		{[]any{"770"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := supportsNIP(tt.nips, 77); got != tt.want {
+			t.Errorf("supportsNIP(%v, 77) = %v, want %v", tt.nips, got, tt.want)
+		}
+	}
+}
+
+func TestSyncable(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter nostr.Filter
+		want   bool
+	}{
+		{"app", nostr.Filter{Kinds: []int{KindApp}, Tags: nostr.TagMap{"d": {"app"}}}, true},
+		{"releases", nostr.Filter{Kinds: []int{KindRelease}, Limit: 10}, true},
+		{"search", nostr.Filter{Kinds: []int{KindApp}, Search: "editor"}, false},
+		{"no kinds", nostr.Filter{IDs: []string{"x"}}, false},
+		{"other kind", nostr.Filter{Kinds: []int{KindApp, 1}}, false},
+	}
+	for _, tt := range tests {
+		if got := syncable(tt.filter); got != tt.want {
+			t.Errorf("%s: syncable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReconciled(t *testing.T) {
+	rel := func(id string, at nostr.Timestamp, app string) *nostr.Event {
+		return &nostr.Event{ID: id, Kind: KindRelease, CreatedAt: at, Tags: nostr.Tags{{"i", app}}}
+	}
+	filter := nostr.Filter{Kinds: []int{KindRelease}, Tags: nostr.TagMap{"i": {"app"}}, Limit: 3}
+
+	local := []*nostr.Event{rel("l1", 100, "app"), rel("l2", 200, "app"), rel("gone", 300, "app")}
+	fetched := []*nostr.Event{rel("f1", 400, "app"), rel("f2", 50, "app"), rel("other", 500, "other")}
+
+	got := reconciled(filter, local, []string{"gone"}, fetched)
+
+	// "gone" is only ours, "other" does not match, the limit drops "f2"
+	want := []string{"f1", "l2", "l1"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d", len(got), len(want))
+	}
+	for i, ev := range got {
+		if ev.ID != want[i] {
+			t.Errorf("event[%d] = %s, want %s", i, ev.ID, want[i])
+		}
+	}
+}
+
+// fakeRelay answers the NIP-77 messages a negotiator writes, reconciling
+// against events, and feeds its replies back through handle as the relay
+// reader would. With refuse set it answers NEG-OPEN with NEG-ERR; with
+// silent it never answers at all.
+type fakeRelay struct {
+	n       *negotiator
+	events  []*nostr.Event
+	refuse  bool
+	silent  bool
+	server  *negentropy.Negentropy
+	written []string
+}
+
+func (f *fakeRelay) write(msg []byte) <-chan error {
+	f.written = append(f.written, string(msg))
+	answer := make(chan error)
+	close(answer)
+	if f.silent {
+		return answer
+	}
+
+	var id, in string
+	switch env := nip77.ParseNegMessage(string(msg)).(type) {
+	case *nip77.OpenEnvelope:
+		if f.refuse {
+			// As NIP-77 spells it; go-nostr marshals NEG-ERROR
+			go f.n.handle(`["NEG-ERR","` + env.SubscriptionID + `","blocked: no sync here"]`)
+			return answer
+		}
+		vec := vector.New()
+		for _, ev := range f.events {
+			vec.Insert(ev.CreatedAt, ev.ID)
+		}
+		vec.Seal()
+		f.server = negentropy.New(vec, 1024*1024)
+		id, in = env.SubscriptionID, env.Message
+	case *nip77.MessageEnvelope:
+		id, in = env.SubscriptionID, env.Message
+	default:
+		return answer // NEG-CLOSE
+	}
+
+	out, err := f.server.Reconcile(in)
+	if err != nil {
+		panic(err)
+	}
+	reply, _ := nip77.MessageEnvelope{SubscriptionID: id, Message: out}.MarshalJSON()
+	go f.n.handle(string(reply))
+	return answer
+}
+
+// syncEvents returns n release events with distinct IDs derived from seed.
+func syncEvents(seed string, n int) []*nostr.Event {
+	evs := make([]*nostr.Event, n)
+	for i := range evs {
+		sum := sha256.Sum256(fmt.Appendf(nil, "%s-%d", seed, i))
+		evs[i] = &nostr.Event{ID: hex.EncodeToString(sum[:]), Kind: KindRelease, CreatedAt: nostr.Timestamp(1000 + i)}
+	}
+	return evs
+}
+
+func ids(evs []*nostr.Event) []string {
+	out := make([]string, len(evs))
+	for i, ev := range evs {
+		out[i] = ev.ID
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestReconcile(t *testing.T) {
+	// Enough events on each side that neither fits one ID channel buffer
+	shared := syncEvents("shared", 300)
+	onlyOurs := syncEvents("ours", 150)
+	onlyTheirs := syncEvents("theirs", 200)
+
+	n := &negotiator{}
+	relay := &fakeRelay{n: n, events: slices.Concat(shared, onlyTheirs)}
+	filter := nostr.Filter{Kinds: []int{KindRelease}}
+
+	haves, haveNots, err := n.reconcile(context.Background(), relay.write, filter, slices.Concat(shared, onlyOurs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(haves)
+	slices.Sort(haveNots)
+	if !slices.Equal(haves, ids(onlyOurs)) {
+		t.Errorf("haves: got %d IDs, want the %d only we have", len(haves), len(onlyOurs))
+	}
+	if !slices.Equal(haveNots, ids(onlyTheirs)) {
+		t.Errorf("haveNots: got %d IDs, want the %d only the relay has", len(haveNots), len(onlyTheirs))
+	}
+	if last := relay.written[len(relay.written)-1]; nip77.ParseNegMessage(last).Label() != "NEG-CLOSE" {
+		t.Errorf("last message written = %s, want NEG-CLOSE", last)
+	}
+	if n.cur != nil {
+		t.Error("reconciliation still registered after it finished")
+	}
+}
+
+func TestReconcileFailureStopsDrains(t *testing.T) {
+	local := syncEvents("ours", 50)
+	filter := nostr.Filter{Kinds: []int{KindRelease}}
+	before := runtime.NumGoroutine()
+
+	tests := []struct {
+		name    string
+		relay   fakeRelay
+		timeout time.Duration
+	}{
+		{"refused", fakeRelay{refuse: true}, negTimeout},
+		{"silent", fakeRelay{silent: true}, 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		n := &negotiator{}
+		tt.relay.n = n
+		ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+		_, _, err := n.reconcile(ctx, tt.relay.write, filter, local)
+		cancel()
+		if err == nil {
+			t.Errorf("%s: reconcile succeeded, want an error", tt.name)
+		}
+		if timedOut := errors.Is(err, context.DeadlineExceeded); timedOut != tt.relay.silent {
+			t.Errorf("%s: reconcile = %v", tt.name, err)
+		}
+	}
+
+	// Every goroutine reconcile started has returned
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := runtime.NumGoroutine(); got > before {
+		t.Errorf("%d goroutines running after failed reconciliations, %d before", got, before)
+	}
+}
+
+func TestSyncFilterProbesOnlyWithCache(t *testing.T) {
+	var probes atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		w.Header().Set("Content-Type", "application/nostr+json")
+		w.Write([]byte(`{"supported_nips":[1,11]}`))
+	}))
+	defer srv.Close()
+	relay := nostr.NewRelay(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"))
+	filter := nostr.Filter{Kinds: []int{KindRelease}}
+
+	// Nothing cached: a REQ is cheaper, so the relay is not even asked
+	useCache(t)
+	if _, ok := syncFilter(context.Background(), relay, &negotiator{}, filter); ok {
+		t.Fatal("syncFilter reconciled against an empty cache")
+	}
+	if n := probes.Load(); n != 0 {
+		t.Errorf("relay information fetched %d times with nothing cached", n)
+	}
+
+	// With releases cached it asks, and falls back since NIP-77 is missing
+	useCache(t, signedEvent(t, KindRelease, nostr.Tags{{"d", "app@1.0"}}))
+	if _, ok := syncFilter(context.Background(), relay, &negotiator{}, filter); ok {
+		t.Fatal("syncFilter reconciled with a relay that lacks NIP-77")
+	}
+	if n := probes.Load(); n != 1 {
+		t.Errorf("relay information fetched %d times, want once", n)
+	}
+}
@@ -3,20 +3,36 @@ package nostr
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/nbd-wtf/go-nostr"
+	"github.com/zapstore/zapstore/ui"
 )
 
 const defaultRelay = "wss://relay.zapstore.dev"
 
-// RelayURL returns the relay URL from RELAY_URL env var, or the default.
-func RelayURL() string {
-	if u := os.Getenv("RELAY_URL"); u != "" {
-		return u
+// Warnf reports non-fatal problems such as a single relay failing while
+// others answered. They go to stderr, where spinners draw, so stdout stays
+// clean when piped. Callers may replace it to redirect or silence warnings.
+var Warnf = ui.StderrWarningf
+
+// Relays returns the relay set to query: the default relay followed by any
+// comma-separated URLs in the RELAY_URL env var. Duplicates are removed.
+func Relays() []string {
+	urls := []string{defaultRelay}
+	for _, u := range strings.Split(os.Getenv("RELAY_URL"), ",") {
+		u = strings.TrimSpace(u)
+		if u == "" {
+			continue
+		}
+		urls = append(urls, u)
 	}
-	return defaultRelay
+	return dedupeURLs(urls)
 }
 
 // Nostr event kinds used by zapstore (NIP-82).
@@ -26,19 +42,180 @@ const (
 	KindAsset   = 3063  // Asset metadata
 )
 
-// QueryEvents connects to the relay, executes the filter, and returns
-// all matching events. The connection is closed after the query.
-func QueryEvents(ctx context.Context, relayURL string, filters nostr.Filters) ([]*nostr.Event, error) {
-	relay, err := nostr.RelayConnect(ctx, relayURL)
+// QueryEvents queries every relay of the client concurrently with all
+// filters and returns the merged result: events with a bad ID or signature
+// are dropped, the rest are de-duplicated by ID and replaceable kinds are
+// collapsed to the newest version per author and `d` tag. Relays that fail
+// are reported through Warnf; an error is returned only if every relay
+// failed.
+//
+// The result is added to the local event cache. In Offline mode the relays
+// are not contacted and the query is answered from the cache alone.
+func (c *Client) QueryEvents(ctx context.Context, filters nostr.Filters) ([]*nostr.Event, error) {
+	if Offline {
+		return queryCache(filters)
+	}
+	relayURLs := c.relayURLs
+	if len(relayURLs) == 0 {
+		return nil, fmt.Errorf("no relays configured")
+	}
+
+	type result struct {
+		url    string
+		events []*nostr.Event
+		err    error
+	}
+
+	results := make([]result, len(relayURLs))
+	var wg sync.WaitGroup
+	for i, url := range relayURLs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			events, err := c.queryRelay(ctx, url, filters)
+			results[i] = result{url: url, events: events, err: err}
+		}()
+	}
+	wg.Wait()
+
+	var all []*nostr.Event
+	var errs []error
+	for _, r := range results {
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+		all = append(all, r.events...)
+	}
+
+	if len(errs) == len(relayURLs) {
+		return nil, errors.Join(errs...)
+	}
+	for _, err := range errs {
+		Warnf("%v", err)
+	}
+
+	valid, err := verifyEvents(all)
+	if err != nil {
+		return nil, err
+	}
+	merged := mergeEvents(valid)
+
+	// The cache only serves --offline; failing to update it is not fatal
+	if cache, err := openCache(); err != nil {
+		Warnf("%v", err)
+	} else if err := cache.add(merged); err != nil {
+		Warnf("%v", err)
+	}
+
+	return merged, nil
+}
+
+// queryCache answers a query from the local event cache. Events are checked
+// again as if a relay had sent them, since the file could have been edited.
+func queryCache(filters nostr.Filters) ([]*nostr.Event, error) {
+	c, err := openCache()
+	if err != nil {
+		return nil, err
+	}
+	valid, err := verifyEvents(c.query(filters))
 	if err != nil {
-		return nil, fmt.Errorf("connecting to relay %s: %w", relayURL, err)
+		return nil, err
 	}
-	defer relay.Close()
+	return mergeEvents(valid), nil
+}
 
-	events, err := relay.QuerySync(ctx, filters[0])
+// queryRelay runs each filter in turn on the client's connection to a
+// single relay, reconciling it against the event cache when the relay
+// supports NIP-77 and with a plain REQ otherwise.
+func (c *Client) queryRelay(ctx context.Context, relayURL string, filters nostr.Filters) ([]*nostr.Event, error) {
+	cn, err := c.acquire(ctx, relayURL)
 	if err != nil {
-		return nil, fmt.Errorf("querying relay: %w", err)
+		return nil, err
+	}
+	defer cn.mu.Unlock()
+
+	var events []*nostr.Event
+	for _, filter := range filters {
+		if evs, ok := syncFilter(ctx, cn.relay, cn.neg, filter); ok {
+			events = append(events, evs...)
+			continue
+		}
+		evs, err := cn.relay.QuerySync(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("querying relay %s: %w", relayURL, err)
+		}
+		events = append(events, evs...)
 	}
 
 	return events, nil
 }
+
+// mergeEvents de-duplicates events by ID and keeps only the newest event
+// for each replaceable address (kind:pubkey:d). The result is sorted newest
+// first, matching the order relays return events in.
+func mergeEvents(events []*nostr.Event) []*nostr.Event {
+	seen := make(map[string]bool, len(events))
+	latest := make(map[string]*nostr.Event)
+
+	var merged []*nostr.Event
+	for _, ev := range events {
+		if seen[ev.ID] {
+			continue
+		}
+		seen[ev.ID] = true
+
+		if !isReplaceable(ev.Kind) {
+			merged = append(merged, ev)
+			continue
+		}
+
+		addr := address(ev)
+		if cur, ok := latest[addr]; !ok || newer(ev, cur) {
+			latest[addr] = ev
+		}
+	}
+
+	for _, ev := range latest {
+		merged = append(merged, ev)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return newer(merged[i], merged[j])
+	})
+	return merged
+}
+
+// isReplaceable reports whether events of this kind supersede earlier ones
+// from the same author (NIP-01 replaceable and addressable kinds).
+func isReplaceable(kind int) bool {
+	return nostr.IsReplaceableKind(kind) || nostr.IsAddressableKind(kind)
+}
+
+// address returns the NIP-01 coordinate for a replaceable event.
+func address(ev *nostr.Event) string {
+	return fmt.Sprintf("%d:%s:%s", ev.Kind, ev.PubKey, tagValue(ev, "d"))
+}
+
+// newer reports whether a should replace b. Ties on created_at are broken by
+// the lowest event ID, as NIP-01 specifies.
+func newer(a, b *nostr.Event) bool {
+	if a.CreatedAt != b.CreatedAt {
+		return a.CreatedAt > b.CreatedAt
+	}
+	return a.ID < b.ID
+}
+
+func dedupeURLs(urls []string) []string {
+	seen := make(map[string]bool, len(urls))
+	var out []string
+	for _, u := range urls {
+		n := nostr.NormalizeURL(u)
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		out = append(out, u)
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package nostr
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestMergeEvents(t *testing.T) {
+	appOld := &nostr.Event{ID: "a1", Kind: KindApp, PubKey: "pk", CreatedAt: 100, Tags: nostr.Tags{{"d", "app"}}}
+	appNew := &nostr.Event{ID: "a2", Kind: KindApp, PubKey: "pk", CreatedAt: 200, Tags: nostr.Tags{{"d", "app"}}}
+	appOther := &nostr.Event{ID: "a3", Kind: KindApp, PubKey: "other", CreatedAt: 50, Tags: nostr.Tags{{"d", "app"}}}
+	asset := &nostr.Event{ID: "x1", Kind: KindAsset, PubKey: "pk", CreatedAt: 150}
+
+	// Same events as returned by two relays, in different orders.
+	got := mergeEvents([]*nostr.Event{appOld, asset, appOther, appNew, asset, appOld})
+
+	want := []string{"a2", "x1", "a3"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d", len(got), len(want))
+	}
+	for i, ev := range got {
+		if ev.ID != want[i] {
+			t.Errorf("event[%d] = %s, want %s", i, ev.ID, want[i])
+		}
+	}
+}
+
+func TestMergeEventsTieBreak(t *testing.T) {
+	a := &nostr.Event{ID: "bb", Kind: KindRelease, PubKey: "pk", CreatedAt: 100, Tags: nostr.Tags{{"d", "app@1.0"}}}
+	b := &nostr.Event{ID: "aa", Kind: KindRelease, PubKey: "pk", CreatedAt: 100, Tags: nostr.Tags{{"d", "app@1.0"}}}
+
+	got := mergeEvents([]*nostr.Event{a, b})
+	if len(got) != 1 || got[0].ID != "aa" {
+		t.Errorf("expected lowest ID to win on equal created_at, got %v", got)
+	}
+}
+
+func TestRelays(t *testing.T) {
+	t.Setenv("RELAY_URL", "wss://relay.example.com, wss://relay.zapstore.dev,,wss://relay.example.com/")
+
+	got := Relays()
+	want := []string{defaultRelay, "wss://relay.example.com"}
+	if len(got) != len(want) {
+		t.Fatalf("Relays() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Relays()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
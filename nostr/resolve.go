@@ -2,11 +2,16 @@ package nostr
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/zapstore/zapstore/platform"
+	"github.com/zapstore/zapstore/store"
+	"github.com/zapstore/zapstore/validate"
 	"github.com/zapstore/zapstore/version"
 )
 
@@ -23,6 +28,9 @@ type AppInfo struct {
 type ReleaseInfo struct {
 	Event   *nostr.Event
 	Version string
+	// Channel is the release's `c` tag, or else implied by its version
+	// (see version.Channel). The "main" tag value counts as stable.
+	Channel string
 	// AssetEventIDs are the `e` tag references to asset events.
 	AssetEventIDs []string
 }
@@ -35,13 +43,23 @@ type AssetInfo struct {
 	Platform string // f tag
 	MIME     string // m tag
 	Filename string // filename tag
+	Size     int64  // size tag in bytes, 0 if absent
+
+	// Executables lists the files to link into bin/ when the asset is an
+	// archive, from its `executables` tag(s). Empty means auto-detect.
+	Executables []string
 }
 
-// ResolveApp queries the relay for a kind 32267 event matching the app ID
+// ResolveApp queries the relays for a kind 32267 event matching the app ID
 // and the current platform. The appID is matched against the `d` tag, and
-// the platform's `f` tag value is sent so the relay only returns apps
+// the platform's `f` tag value is sent so relays only return apps
 // available for this OS/arch.
-func ResolveApp(ctx context.Context, relayURL, appID string, plat platform.Info) (*AppInfo, error) {
+//
+// publisher is the key pinned for the app, or "" if there is none. Its
+// event is preferred over newer ones by other authors, so anyone reusing
+// the app ID cannot pass for a key change; the newest event is returned
+// only when the pinned publisher has none.
+func (c *Client) ResolveApp(ctx context.Context, appID, publisher string, plat platform.Info) (*AppInfo, error) {
 	filters := nostr.Filters{{
 		Kinds: []int{KindApp},
 		Tags: nostr.TagMap{
@@ -50,93 +68,311 @@ func ResolveApp(ctx context.Context, relayURL, appID string, plat platform.Info)
 		},
 		Limit: 1,
 	}}
+	if publisher != "" {
+		pinned := filters[0]
+		pinned.Authors = []string{publisher}
+		filters = append(filters, pinned)
+	}
 
-	events, err := QueryEvents(ctx, relayURL, filters)
+	events, err := c.QueryEvents(ctx, filters)
 	if err != nil {
 		return nil, err
 	}
-	if len(events) == 0 {
-		return nil, fmt.Errorf("app %q not found on relay", appID)
-	}
+	return pickApp(events, appID, publisher)
+}
 
-	return appInfoFromEvent(events[0]), nil
+// pickApp returns the app event for appID among events, which are sorted
+// newest first: the one by publisher if there is one, else the newest. When
+// a newer event by another key is passed over, it warns: the publisher may
+// have rotated keys, which only the user can confirm.
+func pickApp(events []*nostr.Event, appID, publisher string) (*AppInfo, error) {
+	var pick, newer *nostr.Event
+	for _, ev := range events {
+		if tagValue(ev, "d") != appID {
+			continue
+		}
+		if ev.PubKey == publisher {
+			pick = ev
+			break
+		}
+		if newer == nil {
+			newer = ev
+		}
+	}
+	switch {
+	case pick == nil && newer == nil:
+		return nil, &AppNotFoundError{AppID: appID, Offline: Offline}
+	case pick == nil:
+		pick = newer
+	case newer != nil:
+		Warnf("%s has a newer app event signed by %s, not the trusted key; run 'zapstore trust %s' if the publisher changed keys",
+			appID, store.Npub(newer.PubKey), appID)
+	}
+	app := appInfoFromEvent(pick)
+	if err := validate.AppID(app.AppID); err != nil {
+		return nil, err
+	}
+	return app, nil
 }
 
-// ResolveLatestRelease finds the latest release for an app.
+// ResolveLatestRelease finds the latest release for an app on a channel.
 //
-// It queries for kind 30063 events whose `i` tag matches the app ID,
-// then picks the one with the highest version.
-func ResolveLatestRelease(ctx context.Context, relayURL string, app *AppInfo) (*ReleaseInfo, error) {
-	filters := nostr.Filters{{
-		Kinds:   []int{KindRelease},
-		Authors: []string{app.Pubkey},
-		Tags:    nostr.TagMap{"i": []string{app.AppID}},
-	}}
+// It queries for kind 30063 events by the app's publisher whose `i` tag
+// matches the app ID or whose `a` tag points at the app event, drops any
+// that fail the authorship check or are not on the channel (see
+// version.InChannel; "" means stable), then picks the one with the highest
+// version.
+func (c *Client) ResolveLatestRelease(ctx context.Context, app *AppInfo, channel string) (*ReleaseInfo, error) {
+	releases, err := c.queryReleases(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+	return selectRelease(app, releases, "", channel)
+}
+
+// ResolveRelease finds the highest release of an app whose version, as read
+// from its `version` tag or `d` tag, satisfies constraint: an exact version
+// or a range such as ^1.2 (see version.ParseSpec). A release whose version
+// is written exactly as constraint is taken first, so versions such as r123
+// work too. A range only picks releases on the channel, as in
+// ResolveLatestRelease; an exact version is taken from any channel.
+// Returns a *ReleaseNotFoundError listing the available versions if none
+// matches.
+func (c *Client) ResolveRelease(ctx context.Context, app *AppInfo, constraint, channel string) (*ReleaseInfo, error) {
+	// Reject a bad constraint before going online
+	if _, err := version.ParseSpec(constraint); err != nil {
+		return nil, err
+	}
 
-	events, err := QueryEvents(ctx, relayURL, filters)
+	releases, err := c.queryReleases(ctx, app)
 	if err != nil {
 		return nil, err
 	}
-	if len(events) == 0 {
-		return nil, fmt.Errorf("no releases found for %q", app.AppID)
+	return selectRelease(app, releases, constraint, channel)
+}
+
+// selectRelease picks from an app's releases as ResolveLatestRelease does
+// when constraint is empty, and as ResolveRelease does otherwise.
+func selectRelease(app *AppInfo, releases []*ReleaseInfo, constraint, channel string) (*ReleaseInfo, error) {
+	channel = defaultChannel(channel)
+	if constraint == "" {
+		best := latestRelease(releases, channel)
+		if best == nil {
+			return nil, &ReleaseNotFoundError{AppID: app.AppID, Channel: channel, Available: releaseVersions(releases)}
+		}
+		return best, nil
 	}
 
-	// Find the latest version.
-	// The version is extracted from the `d` tag (format: @<version>) or
-	// a `version` tag if present.
-	var best *nostr.Event
-	var bestVersion string
-	for _, ev := range events {
-		ver := extractVersion(ev)
-		if ver == "" {
+	// A literal match needs no parsing
+	for _, r := range releases {
+		if r.Version == constraint {
+			return r, nil
+		}
+	}
+
+	want, err := version.ParseSpec(constraint)
+	if err != nil {
+		return nil, err
+	}
+	if want.IsExact() {
+		channel = version.AnyPre
+	}
+
+	var best *ReleaseInfo
+	for _, r := range releases {
+		if !want.Check(r.Version) || !version.InChannel(r.Channel, channel) {
 			continue
 		}
-		if best == nil || version.Compare(ver, bestVersion) > 0 {
-			best = ev
-			bestVersion = ver
+		if best == nil || version.Compare(r.Version, best.Version) > 0 {
+			best = r
 		}
 	}
-
 	if best == nil {
-		return nil, fmt.Errorf("no versioned releases found for %q", app.AppID)
+		return nil, &ReleaseNotFoundError{AppID: app.AppID, Constraint: constraint, Channel: channel, Available: releaseVersions(releases)}
 	}
+	return best, nil
+}
 
-	// Collect asset event IDs from `e` tags
-	var assetIDs []string
-	for _, tag := range best.Tags {
-		if len(tag) >= 2 && tag[0] == "e" {
-			assetIDs = append(assetIDs, tag[1])
+// AppNotFoundError is returned when no relay, or in Offline mode the event
+// cache, has an app with the requested ID for the current platform.
+type AppNotFoundError struct {
+	AppID   string
+	Offline bool // only the local event cache was searched
+}
+
+func (e *AppNotFoundError) Error() string {
+	if e.Offline {
+		return fmt.Sprintf("app %q not found in the offline cache", e.AppID)
+	}
+	return fmt.Sprintf("app %q not found on any relay", e.AppID)
+}
+
+// ReleaseNotFoundError is returned when no release of an app on the
+// followed channel satisfies the requested version constraint.
+type ReleaseNotFoundError struct {
+	AppID      string
+	Constraint string // empty when looking for the latest release
+	Channel    string
+	Available  []string // highest first, from every channel
+}
+
+func (e *ReleaseNotFoundError) Error() string {
+	what := "release"
+	if e.Channel != version.AnyPre {
+		what = e.Channel + " release"
+	}
+	msg := fmt.Sprintf("no %s of %q", what, e.AppID)
+	if e.Constraint != "" {
+		msg += " matches " + e.Constraint
+	}
+	msg += "; available: " + strings.Join(e.Available, ", ")
+	if e.Channel == version.Stable {
+		msg += " (use --pre to include pre-releases)"
+	}
+	return msg
+}
+
+// queryReleases fetches every release of an app, dropping those that fail
+// the authorship check or carry no usable version. Releases are returned
+// newest event first; the result is never empty when err is nil.
+func (c *Client) queryReleases(ctx context.Context, app *AppInfo) ([]*ReleaseInfo, error) {
+	events, err := c.QueryEvents(ctx, releaseFilters([]*AppInfo{app}))
+	if err != nil {
+		return nil, err
+	}
+	return appReleases(app, events)
+}
+
+// releaseFilters selects the releases of apps: events by their publishers
+// whose `i` tag names one of them or whose `a` tag points at one.
+func releaseFilters(apps []*AppInfo) nostr.Filters {
+	var authors, appIDs, addrs []string
+	for _, app := range apps {
+		if !slices.Contains(authors, app.Pubkey) {
+			authors = append(authors, app.Pubkey)
 		}
+		appIDs = append(appIDs, app.AppID)
+		addrs = append(addrs, appAddress(app))
 	}
+	return nostr.Filters{
+		{Kinds: []int{KindRelease}, Authors: authors, Tags: nostr.TagMap{"i": appIDs}},
+		{Kinds: []int{KindRelease}, Authors: authors, Tags: nostr.TagMap{"a": addrs}},
+	}
+}
 
-	return &ReleaseInfo{
-		Event:         best,
-		Version:       bestVersion,
-		AssetEventIDs: assetIDs,
-	}, nil
+// ownReleases returns the events among a query for several apps' releases
+// that belong to app.
+func ownReleases(app *AppInfo, events []*nostr.Event) []*nostr.Event {
+	var own []*nostr.Event
+	for _, ev := range events {
+		if checkRelease(app, ev) == nil {
+			own = append(own, ev)
+		}
+	}
+	return own
+}
+
+// appReleases turns the release events fetched for app into releases, as
+// described for queryReleases.
+func appReleases(app *AppInfo, events []*nostr.Event) ([]*ReleaseInfo, error) {
+	if len(events) == 0 {
+		return nil, fmt.Errorf("no releases found for %q", app.AppID)
+	}
+
+	var chainErr error
+	events = slices.DeleteFunc(events, func(ev *nostr.Event) bool {
+		if err := checkRelease(app, ev); err != nil {
+			Warnf("dropping %v", err)
+			chainErr = err
+			return true
+		}
+		return false
+	})
+	if len(events) == 0 {
+		return nil, chainErr
+	}
+
+	releases, invalid := parseReleases(events)
+	if len(releases) == 0 {
+		if invalid != nil {
+			return nil, invalid
+		}
+		return nil, fmt.Errorf("no versioned releases found for %q", app.AppID)
+	}
+	return releases, nil
+}
+
+// LatestReleases finds the latest release on channel of each app with a
+// single query, keyed by app ID, as ResolveLatestRelease would for each
+// one. Apps with no release on the channel are left out.
+func (c *Client) LatestReleases(ctx context.Context, apps []*AppInfo, channel string) (map[string]*ReleaseInfo, error) {
+	if len(apps) == 0 {
+		return map[string]*ReleaseInfo{}, nil
+	}
+
+	events, err := c.QueryEvents(ctx, releaseFilters(apps))
+	if err != nil {
+		return nil, err
+	}
+
+	channel = defaultChannel(channel)
+	latest := make(map[string]*ReleaseInfo)
+	for _, app := range apps {
+		releases, _ := parseReleases(ownReleases(app, events))
+		if best := latestRelease(releases, channel); best != nil {
+			latest[app.AppID] = best
+		}
+	}
+	return latest, nil
 }
 
 // ResolveAssets fetches the asset events referenced by a release and filters
-// them for the current platform. Queries both kind 3063 and kind 1063 for
-// compatibility with older events.
-func ResolveAssets(ctx context.Context, relayURL string, assetEventIDs []string, plat platform.Info) ([]*AssetInfo, error) {
-	if len(assetEventIDs) == 0 {
-		return nil, fmt.Errorf("release has no asset references")
+// them for the current platform. Every asset must be signed by the app's
+// publisher; a referenced asset from any other author fails with a
+// *ChainError rather than being skipped.
+func (c *Client) ResolveAssets(ctx context.Context, app *AppInfo, release *ReleaseInfo, plat platform.Info) ([]*AssetInfo, error) {
+	if len(release.AssetEventIDs) == 0 {
+		return nil, errNoAssetRefs
 	}
 
-	// Query by event ID, filtered to our platform's f tag.
+	// Query by event ID and author, filtered to our platform's f tag.
 	filters := nostr.Filters{{
-		IDs:  assetEventIDs,
-		Tags: nostr.TagMap{"f": []string{plat.Platform}},
+		IDs:     release.AssetEventIDs,
+		Authors: []string{app.Pubkey},
+		Tags:    nostr.TagMap{"f": []string{plat.Platform}},
 	}}
 
-	events, err := QueryEvents(ctx, relayURL, filters)
+	events, err := c.QueryEvents(ctx, filters)
 	if err != nil {
 		return nil, err
 	}
+	return matchAssets(app, release, events, plat)
+}
+
+// errNoAssetRefs is returned for a release without `e` tags.
+var errNoAssetRefs = errors.New("release has no asset references")
 
+// matchAssets returns the assets of release among events that suit the
+// platform, as described for ResolveAssets. Events of other releases are
+// ignored.
+func matchAssets(app *AppInfo, release *ReleaseInfo, events []*nostr.Event, plat platform.Info) ([]*AssetInfo, error) {
 	var matched []*AssetInfo
+	var invalid error
 	for _, ev := range events {
+		if !slices.Contains(release.AssetEventIDs, ev.ID) {
+			continue // another release's, or sent without being asked for
+		}
+		if err := checkAsset(app, release, ev); err != nil {
+			return nil, err
+		}
+		if name := tagValue(ev, "filename"); name != "" {
+			if err := validate.FileName(name); err != nil {
+				Warnf("dropping asset %s: %v", shortID(ev.ID), err)
+				invalid = err
+				continue
+			}
+		}
+
 		fTag := tagValue(ev, "f")
 		mTag := tagValue(ev, "m")
 
@@ -153,15 +389,18 @@ func ResolveAssets(ctx context.Context, relayURL string, assetEventIDs []string,
 	}
 
 	if len(matched) == 0 {
+		if invalid != nil {
+			return nil, invalid
+		}
 		return nil, fmt.Errorf("no assets found for platform %s", plat.Platform)
 	}
 
 	return matched, nil
 }
 
-// SearchApps queries the relay for apps matching a search string,
+// SearchApps queries the relays for apps matching a search string,
 // filtered to the current platform.
-func SearchApps(ctx context.Context, relayURL, query string, plat platform.Info) ([]*AppInfo, error) {
+func (c *Client) SearchApps(ctx context.Context, query string, plat platform.Info) ([]*AppInfo, error) {
 	filters := nostr.Filters{{
 		Kinds:  []int{KindApp},
 		Tags:   nostr.TagMap{"f": []string{plat.Platform}},
@@ -169,33 +408,46 @@ func SearchApps(ctx context.Context, relayURL, query string, plat platform.Info)
 		Limit:  20,
 	}}
 
-	events, err := QueryEvents(ctx, relayURL, filters)
+	events, err := c.QueryEvents(ctx, filters)
 	if err != nil {
 		return nil, err
 	}
 
 	var results []*AppInfo
 	for _, ev := range events {
-		results = append(results, appInfoFromEvent(ev))
+		app := appInfoFromEvent(ev)
+		if validate.AppID(app.AppID) != nil {
+			continue
+		}
+		results = append(results, app)
 	}
 
 	return results, nil
 }
 
 // Resolve performs the full resolution chain: app → release → asset.
-// Returns the app info, release info, and the best matching asset.
-func Resolve(ctx context.Context, relayURL, appID string, plat platform.Info) (*AppInfo, *ReleaseInfo, *AssetInfo, error) {
-	app, err := ResolveApp(ctx, relayURL, appID, plat)
+// The app event is picked as by ResolveApp, preferring publisher's. An
+// empty constraint selects the latest release on channel, anything else
+// the highest release satisfying it. Returns the app info, release info,
+// and the best matching asset. The three queries share the client's
+// connections.
+func (c *Client) Resolve(ctx context.Context, appID, publisher, constraint, channel string, plat platform.Info) (*AppInfo, *ReleaseInfo, *AssetInfo, error) {
+	app, err := c.ResolveApp(ctx, appID, publisher, plat)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	release, err := ResolveLatestRelease(ctx, relayURL, app)
+	var release *ReleaseInfo
+	if constraint == "" {
+		release, err = c.ResolveLatestRelease(ctx, app, channel)
+	} else {
+		release, err = c.ResolveRelease(ctx, app, constraint, channel)
+	}
 	if err != nil {
 		return app, nil, nil, err
 	}
 
-	assets, err := ResolveAssets(ctx, relayURL, release.AssetEventIDs, plat)
+	assets, err := c.ResolveAssets(ctx, app, release, plat)
 	if err != nil {
 		return app, release, nil, err
 	}
@@ -217,6 +469,18 @@ func tagValue(ev *nostr.Event, key string) string {
 	return ""
 }
 
+// tagValues returns every value of every tag with the given key, so both
+// ["k", "a", "b"] and repeated ["k", "a"], ["k", "b"] tags are supported.
+func tagValues(ev *nostr.Event, key string) []string {
+	var values []string
+	for _, tag := range ev.Tags {
+		if len(tag) >= 2 && tag[0] == key {
+			values = append(values, tag[1:]...)
+		}
+	}
+	return values
+}
+
 // extractVersion gets the version from a release event.
 // Checks for a `version` tag first, then parses the `d` tag (format: @<version>).
 func extractVersion(ev *nostr.Event) string {
@@ -230,6 +494,86 @@ func extractVersion(ev *nostr.Event) string {
 	return d
 }
 
+func releaseFromEvent(ev *nostr.Event, ver string) *ReleaseInfo {
+	// Collect asset event IDs from `e` tags
+	var assetIDs []string
+	for _, tag := range ev.Tags {
+		if len(tag) >= 2 && tag[0] == "e" {
+			assetIDs = append(assetIDs, tag[1])
+		}
+	}
+	channel := strings.ToLower(tagValue(ev, "c"))
+	switch channel {
+	case "":
+		channel = version.Channel(ver)
+	case "main":
+		channel = version.Stable
+	}
+	return &ReleaseInfo{
+		Event:         ev,
+		Version:       ver,
+		Channel:       channel,
+		AssetEventIDs: assetIDs,
+	}
+}
+
+// parseReleases reads the version of each release event, from its
+// `version` tag or its `d` tag (format: @<version>). Events without a
+// version are skipped and those with an unsafe one dropped with a warning;
+// the last such error is returned alongside the releases.
+func parseReleases(events []*nostr.Event) ([]*ReleaseInfo, error) {
+	var releases []*ReleaseInfo
+	var invalid error
+	for _, ev := range events {
+		ver := extractVersion(ev)
+		if ver == "" {
+			continue
+		}
+		if err := validate.Version(ver); err != nil {
+			Warnf("dropping release %s: %v", shortID(ev.ID), err)
+			invalid = err
+			continue
+		}
+		releases = append(releases, releaseFromEvent(ev, ver))
+	}
+	return releases, invalid
+}
+
+// latestRelease returns the highest release on channel, or nil if there
+// is none.
+func latestRelease(releases []*ReleaseInfo, channel string) *ReleaseInfo {
+	var best *ReleaseInfo
+	for _, r := range releases {
+		if !version.InChannel(r.Channel, channel) {
+			continue
+		}
+		if best == nil || version.Compare(r.Version, best.Version) > 0 {
+			best = r
+		}
+	}
+	return best
+}
+
+// defaultChannel returns channel, or stable if it is empty.
+func defaultChannel(channel string) string {
+	if channel == "" {
+		return version.Stable
+	}
+	return channel
+}
+
+// releaseVersions returns the distinct versions of releases, highest first.
+func releaseVersions(releases []*ReleaseInfo) []string {
+	var versions []string
+	for _, r := range releases {
+		if !slices.Contains(versions, r.Version) {
+			versions = append(versions, r.Version)
+		}
+	}
+	slices.SortFunc(versions, func(a, b string) int { return version.Compare(b, a) })
+	return versions
+}
+
 func appInfoFromEvent(ev *nostr.Event) *AppInfo {
 	appID := tagValue(ev, "d")
 	name := tagValue(ev, "name")
@@ -264,6 +608,8 @@ func assetFromEvent(ev *nostr.Event) *AssetInfo {
 		}
 	}
 
+	size, _ := strconv.ParseInt(tagValue(ev, "size"), 10, 64)
+
 	return &AssetInfo{
 		Event:    ev,
 		URL:      url,
@@ -271,5 +617,8 @@ func assetFromEvent(ev *nostr.Event) *AssetInfo {
 		Platform: tagValue(ev, "f"),
 		MIME:     tagValue(ev, "m"),
 		Filename: tagValue(ev, "filename"),
+		Size:     size,
+
+		Executables: tagValues(ev, "executables"),
 	}
 }
@@ -0,0 +1,164 @@
+package nostr
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestExtractVersion(t *testing.T) {
+	tests := []struct {
+		tags nostr.Tags
+		want string
+	}{
+		{nostr.Tags{{"version", "1.4.2"}, {"d", "com.example.tool@1.4.1"}}, "1.4.2"},
+		{nostr.Tags{{"d", "@1.4.2"}}, "1.4.2"},
+		{nostr.Tags{{"d", "1.4.2"}}, "1.4.2"},
+		{nostr.Tags{}, ""},
+	}
+	for _, tt := range tests {
+		if got := extractVersion(&nostr.Event{Tags: tt.tags}); got != tt.want {
+			t.Errorf("extractVersion(%v) = %q, want %q", tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestReleaseVersions(t *testing.T) {
+	releases := []*ReleaseInfo{
+		{Version: "1.2.0"},
+		{Version: "1.10.0"},
+		{Version: "1.2.0"},
+		{Version: "2.0.0-rc.1"},
+		{Version: "1.9"},
+	}
+	got := releaseVersions(releases)
+	want := []string{"2.0.0-rc.1", "1.10.0", "1.9", "1.2.0"}
+	if !slices.Equal(got, want) {
+		t.Errorf("releaseVersions = %v, want %v", got, want)
+	}
+}
+
+func TestReleaseChannel(t *testing.T) {
+	tests := []struct {
+		ver  string
+		tags nostr.Tags
+		want string
+	}{
+		{"1.0.0", nil, "stable"},
+		{"2.0.0-rc.1", nil, "rc"},
+		{"2.0.0-rc.1", nostr.Tags{{"c", "beta"}}, "beta"},
+		{"2.0.0", nostr.Tags{{"c", "Nightly"}}, "nightly"},
+		{"2.0.0", nostr.Tags{{"c", "main"}}, "stable"},
+	}
+	for _, tt := range tests {
+		r := releaseFromEvent(&nostr.Event{Tags: tt.tags}, tt.ver)
+		if r.Channel != tt.want {
+			t.Errorf("releaseFromEvent(%s, %v).Channel = %q, want %q", tt.ver, tt.tags, r.Channel, tt.want)
+		}
+	}
+}
+
+func TestPickApp(t *testing.T) {
+	events := []*nostr.Event{
+		{ID: "b", PubKey: "pk2", Tags: nostr.Tags{{"d", "com.example.other"}}},
+		{ID: "a2", PubKey: "pk1", Tags: nostr.Tags{{"d", "com.example.tool"}, {"name", "Tool"}}},
+		{ID: "a1", PubKey: "pk3", Tags: nostr.Tags{{"d", "com.example.tool"}}},
+	}
+
+	app, err := pickApp(events, "com.example.tool", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.Event.ID != "a2" || app.Name != "Tool" {
+		t.Errorf("pickApp picked %s (%q), want the newest event a2", app.Event.ID, app.Name)
+	}
+
+	// A newer event by someone else does not displace the pinned publisher's,
+	// but it is reported as a possible key change
+	var warnings []string
+	defer func(orig func(string, ...any)) { Warnf = orig }(Warnf)
+	Warnf = func(format string, args ...any) { warnings = append(warnings, fmt.Sprintf(format, args...)) }
+
+	app, err = pickApp(events, "com.example.tool", "pk3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.Event.ID != "a1" {
+		t.Errorf("pickApp(pinned pk3) picked %s, want a1", app.Event.ID)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "zapstore trust com.example.tool") {
+		t.Errorf("pickApp(pinned pk3) warned %q, want a pointer to zapstore trust", warnings)
+	}
+
+	// The pinned publisher's event being the newest is no news
+	warnings = nil
+	if _, err := pickApp(events, "com.example.tool", "pk1"); err != nil || len(warnings) != 0 {
+		t.Errorf("pickApp(pinned pk1) = %v, warned %q; want no warning", err, warnings)
+	}
+
+	// Without an event by the pinned publisher, the newest is reported
+	app, err = pickApp(events, "com.example.tool", "pk9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.Event.ID != "a2" {
+		t.Errorf("pickApp(pinned pk9) picked %s, want a2", app.Event.ID)
+	}
+
+	var notFound *AppNotFoundError
+	if _, err := pickApp(events, "com.example.missing", ""); !errors.As(err, &notFound) {
+		t.Errorf("pickApp(missing) = %v, want *AppNotFoundError", err)
+	}
+}
+
+func TestSelectRelease(t *testing.T) {
+	app := &AppInfo{AppID: "com.example.tool"}
+	releases := []*ReleaseInfo{
+		{Version: "1.2.0", Channel: "stable"},
+		{Version: "1.3.0", Channel: "stable"},
+		{Version: "2.0.0", Channel: "stable"},
+		{Version: "2.1.0-rc.1", Channel: "rc"},
+		{Version: "r123", Channel: "stable"},
+		{Version: "r124", Channel: "stable"},
+		{Version: "1.0~beta", Channel: "stable"},
+	}
+
+	tests := []struct {
+		constraint, channel string
+		want                string
+	}{
+		{"", "", "2.0.0"},
+		{"", "rc", "2.1.0-rc.1"},
+		{"^1.2", "", "1.3.0"},
+		{"1.2.0", "", "1.2.0"},
+		{"2.1.0-rc.1", "", "2.1.0-rc.1"}, // an exact version ignores the channel
+		{"r123", "", "r123"},             // not a constraint, matched as written
+		{"r124", "", "r124"},
+		{"1.0~beta", "", "1.0~beta"},
+	}
+	for _, tt := range tests {
+		got, err := selectRelease(app, releases, tt.constraint, tt.channel)
+		if err != nil {
+			t.Errorf("selectRelease(%q, %q): %v", tt.constraint, tt.channel, err)
+			continue
+		}
+		if got.Version != tt.want {
+			t.Errorf("selectRelease(%q, %q) = %s, want %s", tt.constraint, tt.channel, got.Version, tt.want)
+		}
+	}
+
+	var notFound *ReleaseNotFoundError
+	if _, err := selectRelease(app, releases, "^3", ""); !errors.As(err, &notFound) {
+		t.Errorf("selectRelease(^3) = %v, want *ReleaseNotFoundError", err)
+	}
+	if _, err := selectRelease(app, releases, "r125", ""); !errors.As(err, &notFound) {
+		t.Errorf("selectRelease(r125) = %v, want *ReleaseNotFoundError", err)
+	}
+	if _, err := selectRelease(app, releases, "^r1", ""); err == nil || errors.As(err, &notFound) {
+		t.Errorf("selectRelease(^r1) = %v, want a constraint error", err)
+	}
+}
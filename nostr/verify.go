@@ -0,0 +1,54 @@
+package nostr
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// ErrInvalidEvent is returned when relays answered a query but none of the
+// events they sent carried a valid ID and signature.
+var ErrInvalidEvent = errors.New("no events with a valid ID and signature")
+
+// VerifyEvent recomputes the event ID from its serialized content and checks
+// the Schnorr signature against the event's pubkey.
+func VerifyEvent(ev *nostr.Event) error {
+	if !ev.CheckID() {
+		return fmt.Errorf("event %s: ID does not match content", shortID(ev.ID))
+	}
+	ok, err := ev.CheckSignature()
+	if err != nil {
+		return fmt.Errorf("event %s: %w", shortID(ev.ID), err)
+	}
+	if !ok {
+		return fmt.Errorf("event %s: invalid signature", shortID(ev.ID))
+	}
+	return nil
+}
+
+// verifyEvents drops every event that fails VerifyEvent, reporting each one
+// through Warnf. If events were supplied but none are valid, ErrInvalidEvent
+// is returned so callers don't mistake forged data for "not found".
+func verifyEvents(events []*nostr.Event) ([]*nostr.Event, error) {
+	valid := events[:0:0]
+	for _, ev := range events {
+		if err := VerifyEvent(ev); err != nil {
+			Warnf("dropping %v", err)
+			continue
+		}
+		valid = append(valid, ev)
+	}
+
+	if len(events) > 0 && len(valid) == 0 {
+		return nil, fmt.Errorf("%w (%d rejected)", ErrInvalidEvent, len(events))
+	}
+	return valid, nil
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
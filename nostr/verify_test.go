@@ -0,0 +1,59 @@
+package nostr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func signedEvent(t *testing.T, kind int, tags nostr.Tags) *nostr.Event {
+	t.Helper()
+	ev := &nostr.Event{Kind: kind, CreatedAt: nostr.Now(), Tags: tags}
+	if err := ev.Sign(nostr.GeneratePrivateKey()); err != nil {
+		t.Fatal(err)
+	}
+	return ev
+}
+
+func TestVerifyEvent(t *testing.T) {
+	ev := signedEvent(t, KindAsset, nostr.Tags{{"x", "abc"}, {"url", "https://example.com/bin"}})
+	if err := VerifyEvent(ev); err != nil {
+		t.Fatalf("valid event rejected: %v", err)
+	}
+
+	// Tampering with a tag changes the serialized content, so the ID no longer matches.
+	tampered := *ev
+	tampered.Tags = nostr.Tags{{"x", "abc"}, {"url", "https://evil.example.com/bin"}}
+	if err := VerifyEvent(&tampered); err == nil {
+		t.Error("expected tampered tags to be rejected")
+	}
+
+	// Recomputing the ID after tampering still fails the signature check.
+	tampered.ID = tampered.GetID()
+	if err := VerifyEvent(&tampered); err == nil {
+		t.Error("expected re-hashed tampered event to be rejected")
+	}
+}
+
+func TestVerifyEvents(t *testing.T) {
+	good := signedEvent(t, KindApp, nostr.Tags{{"d", "app"}})
+	bad := signedEvent(t, KindApp, nostr.Tags{{"d", "app"}})
+	bad.Sig = good.Sig
+
+	valid, err := verifyEvents([]*nostr.Event{good, bad})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(valid) != 1 || valid[0] != good {
+		t.Errorf("expected only the valid event, got %d", len(valid))
+	}
+
+	if _, err := verifyEvents([]*nostr.Event{bad}); !errors.Is(err, ErrInvalidEvent) {
+		t.Errorf("expected ErrInvalidEvent, got %v", err)
+	}
+
+	if valid, err := verifyEvents(nil); err != nil || len(valid) != 0 {
+		t.Errorf("empty input: got %v, %v", valid, err)
+	}
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/zapstore/zapstore/version"
+)
+
+// Config represents the contents of config.json: user settings that apply
+// to every package.
+type Config struct {
+	// Channel is the release channel followed by packages without their
+	// own. Empty means stable.
+	Channel string `json:"channel,omitempty"`
+}
+
+// configPath returns the path to config.json.
+func configPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// LoadConfig reads the settings from disk. Returns defaults if the file
+// does not exist.
+func LoadConfig() (*Config, error) {
+	p, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+	return &c, nil
+}
+
+// Save writes the settings to disk, creating the directory if needed.
+func (c *Config) Save() error {
+	p, err := configPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("creating config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling config: %w", err)
+	}
+
+	return WriteFileAtomic(p, data, 0o644)
+}
+
+// ChannelFor returns the release channel a package follows: its own
+// setting, else the global one, else stable. pkg may be nil for a package
+// that is not installed yet.
+func (c *Config) ChannelFor(pkg *Package) string {
+	if pkg != nil && pkg.Channel != "" {
+		return pkg.Channel
+	}
+	if c.Channel != "" {
+		return c.Channel
+	}
+	return version.Stable
+}
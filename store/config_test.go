@@ -0,0 +1,30 @@
+package store
+
+import "testing"
+
+func TestConfigChannelFor(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	c, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.ChannelFor(nil); got != "stable" {
+		t.Errorf("default channel = %q, want stable", got)
+	}
+
+	c.Channel = "beta"
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	c, err = LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.ChannelFor(&Package{}); got != "beta" {
+		t.Errorf("global channel = %q, want beta", got)
+	}
+	if got := c.ChannelFor(&Package{Channel: "rc"}); got != "rc" {
+		t.Errorf("per-app channel = %q, want rc", got)
+	}
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Lock is an advisory lock on the state directory. Commands that modify
+// installed packages, state.json, trust.json or the cache hold it for their
+// whole run so concurrent zapstore processes cannot interleave their writes.
+//
+// The lock is tied to the open file, so the operating system releases it if
+// the process dies.
+type Lock struct {
+	f *os.File
+}
+
+// LockedError is returned when another zapstore process holds the lock.
+type LockedError struct {
+	PID int // 0 if the holder has not recorded its pid yet
+}
+
+func (e *LockedError) Error() string {
+	if e.PID == 0 {
+		return "another zapstore process is running; use --wait to wait for it"
+	}
+	return fmt.Sprintf("another zapstore process is running (pid %d); use --wait to wait for it", e.PID)
+}
+
+// lockPath returns the path to the lock file.
+func lockPath() (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "lock"), nil
+}
+
+// TryLock takes the lock without blocking. Returns a *LockedError if another
+// process holds it.
+func TryLock() (*Lock, error) {
+	return acquire(false)
+}
+
+// WaitLock takes the lock, blocking until any other holder releases it.
+func WaitLock() (*Lock, error) {
+	return acquire(true)
+}
+
+func acquire(wait bool) (*Lock, error) {
+	p, err := lockPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return nil, fmt.Errorf("creating state directory: %w", err)
+	}
+
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock file: %w", err)
+	}
+
+	locked, err := lockFile(f, wait)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("locking %s: %w", p, err)
+	}
+	if !locked {
+		f.Close()
+		return nil, &LockedError{PID: readPID(p)}
+	}
+
+	// Record our pid for the benefit of processes that find the lock taken
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Release gives up the lock. It is safe to call on a nil Lock.
+func (l *Lock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	l.f.Truncate(0)
+	err := unlockFile(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
+
+// readPID returns the pid recorded in the lock file, or 0 if there is none.
+func readPID(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
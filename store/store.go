@@ -4,10 +4,23 @@
 //
 //	packages/<app-id>/<version>/<binary>   ← actual files
 //	bin/<binary>                           ← symlinks
+//	share/man/, share/*/completions        ← man page and completion symlinks
 //
 // State (XDG_STATE_HOME, default ~/.local/state/zapstore):
 //
 //	state.json                             ← installed package metadata
+//	trust.json                             ← pinned publisher keys
+//	lock                                   ← held by commands that modify state
+//
+// Cache (XDG_CACHE_HOME, default ~/.cache/zapstore):
+//
+//	blobs/<sha256>                         ← verified downloads
+//	partial/<sha256>.part                  ← interrupted downloads
+//	events.json                            ← events fetched from relays
+//
+// Config (XDG_CONFIG_HOME, default ~/.config/zapstore):
+//
+//	config.json                            ← user settings
 //
 // Legacy path ~/.zapstore is migrated automatically on first use.
 package store
@@ -27,8 +40,38 @@ type Package struct {
 	InstalledAt  string   `json:"installed_at"`
 	Executables  []string `json:"executables"`
 	AssetEventID string   `json:"asset_event_id"`
+
+	// Extras are man page and shell completion links under share/,
+	// relative to the data directory.
+	Extras []string `json:"extras,omitempty"`
+
+	// Constraint limits which releases install and update may pick, e.g.
+	// ^1.2 from `install app@^1.2`. Empty means the latest release.
+	Constraint string `json:"constraint,omitempty"`
+
+	// Links maps every link this version owns, relative to the data
+	// directory (bin/tool, share/man/man1/tool.1), to its target relative
+	// to the version directory, so it can be relinked without the network.
+	Links map[string]string `json:"links,omitempty"`
+
+	// Previous holds earlier versions still on disk, most recently active
+	// first. Entries only carry per-version fields; their own Previous,
+	// Keep, Constraint and Channel are empty.
+	Previous []*Package `json:"previous,omitempty"`
+
+	// Keep is how many versions to keep on disk, the active one included.
+	// Zero means DefaultKeep.
+	Keep int `json:"keep,omitempty"`
+
+	// Channel is the release channel this package follows, overriding
+	// Config.Channel. Empty means the global setting.
+	Channel string `json:"channel,omitempty"`
 }
 
+// DefaultKeep is the number of versions of each package kept on disk
+// unless set per package: the active one plus two to switch back to.
+const DefaultKeep = 3
+
 // State represents the full contents of state.json.
 type State struct {
 	Packages map[string]*Package `json:"packages"`
@@ -60,6 +103,62 @@ func StateDir() (string, error) {
 	return filepath.Join(home, ".local", "state", "zapstore"), nil
 }
 
+// CacheDir returns the zapstore cache directory.
+// Respects XDG_CACHE_HOME; defaults to ~/.cache/zapstore.
+func CacheDir() (string, error) {
+	if xdg := os.Getenv("XDG_CACHE_HOME"); xdg != "" {
+		return filepath.Join(xdg, "zapstore"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".cache", "zapstore"), nil
+}
+
+// ConfigDir returns the zapstore config directory.
+// Respects XDG_CONFIG_HOME; defaults to ~/.config/zapstore.
+func ConfigDir() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "zapstore"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "zapstore"), nil
+}
+
+// PartialDir returns the directory holding interrupted downloads, named by
+// their expected SHA-256 so they can be resumed.
+func PartialDir() (string, error) {
+	d, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, "partial"), nil
+}
+
+// BlobDir returns the content-addressed download cache, where verified
+// downloads are stored by SHA-256 and shared across installs.
+func BlobDir() (string, error) {
+	d, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, "blobs"), nil
+}
+
+// EventsPath returns the path to the local event cache, where events
+// fetched from relays are kept for offline use.
+func EventsPath() (string, error) {
+	d, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, "events.json"), nil
+}
+
 // BinDir returns the path to ~/.local/share/zapstore/bin.
 func BinDir() (string, error) {
 	d, err := DataDir()
@@ -125,7 +224,7 @@ func MigrateIfNeeded() error {
 		return fmt.Errorf("migrating data directory: %w", err)
 	}
 
-	fmt.Printf("Migrated ~/.zapstore → %s\n", dataDir)
+	fmt.Fprintf(os.Stderr, "Migrated ~/.zapstore → %s\n", dataDir)
 	return nil
 }
 
@@ -180,14 +279,75 @@ func (s *State) Save() error {
 		return fmt.Errorf("marshaling state: %w", err)
 	}
 
-	return os.WriteFile(p, data, 0o644)
+	return WriteFileAtomic(p, data, 0o644)
+}
+
+// WriteFileAtomic replaces path with data so that readers and crashes only
+// ever see the old or the new contents: the data is written and synced to a
+// temp file in the same directory, then renamed over path.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// Persist the rename itself; not every platform can sync a directory
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
 }
 
-// Add records a newly installed package.
+// Add records a newly active version of a package. The version it replaces
+// moves to the front of Previous, the retention and channel settings carry
+// over, and versions beyond the retention count are dropped; see Versions
+// for what to keep on disk.
 func (s *State) Add(appID string, pkg *Package) {
 	if pkg.InstalledAt == "" {
 		pkg.InstalledAt = time.Now().UTC().Format(time.RFC3339)
 	}
+
+	var previous []*Package
+	if old := s.Packages[appID]; old != nil {
+		if pkg.Keep == 0 {
+			pkg.Keep = old.Keep
+		}
+		if pkg.Channel == "" {
+			pkg.Channel = old.Channel
+		}
+		if old.Version != pkg.Version {
+			previous = append(previous, old.release())
+		}
+		for _, p := range old.Previous {
+			if p.Version != pkg.Version {
+				previous = append(previous, p)
+			}
+		}
+	}
+	pkg.Previous = previous
+	pkg.trim()
+
 	s.Packages[appID] = pkg
 }
 
@@ -200,3 +360,45 @@ func (s *State) Remove(appID string) {
 func (s *State) Get(appID string) *Package {
 	return s.Packages[appID]
 }
+
+// Versions returns the versions of the package kept on disk, the active
+// one first.
+func (p *Package) Versions() []string {
+	versions := []string{p.Version}
+	for _, prev := range p.Previous {
+		versions = append(versions, prev.Version)
+	}
+	return versions
+}
+
+// KeepCount returns how many versions of the package to keep on disk.
+func (p *Package) KeepCount() int {
+	if p.Keep > 0 {
+		return p.Keep
+	}
+	return DefaultKeep
+}
+
+// SetKeep changes the retention count and drops versions beyond it.
+func (p *Package) SetKeep(n int) {
+	p.Keep = n
+	p.trim()
+}
+
+// trim drops previous versions beyond the retention count.
+func (p *Package) trim() {
+	if n := p.KeepCount() - 1; len(p.Previous) > n {
+		p.Previous = p.Previous[:n]
+	}
+}
+
+// release returns a copy of the package's per-version fields, for keeping
+// in another entry's Previous.
+func (p *Package) release() *Package {
+	r := *p
+	r.Previous = nil
+	r.Keep = 0
+	r.Constraint = ""
+	r.Channel = ""
+	return &r
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAddKeepsPreviousVersions(t *testing.T) {
+	state := &State{Packages: make(map[string]*Package)}
+
+	for _, v := range []string{"1.0", "1.1", "1.2", "1.3"} {
+		state.Add("app", &Package{Version: v, Links: map[string]string{"bin/tool": "tool"}})
+	}
+
+	pkg := state.Get("app")
+	if got, want := pkg.Versions(), []string{"1.3", "1.2", "1.1"}; !slices.Equal(got, want) {
+		t.Errorf("Versions() = %v, want %v", got, want)
+	}
+	if pkg.Previous[0].Links["bin/tool"] != "tool" {
+		t.Error("previous version lost its links")
+	}
+
+	// Reinstalling the active version does not push it into its own history
+	state.Add("app", &Package{Version: "1.3"})
+	if got, want := state.Get("app").Versions(), []string{"1.3", "1.2", "1.1"}; !slices.Equal(got, want) {
+		t.Errorf("after reinstall Versions() = %v, want %v", got, want)
+	}
+}
+
+func TestAddSwitchesToPreviousVersion(t *testing.T) {
+	state := &State{Packages: make(map[string]*Package)}
+	state.Add("app", &Package{Version: "1.0"})
+	state.Add("app", &Package{Version: "2.0", Keep: 5, Constraint: "^2", Channel: "rc"})
+
+	// Activating a kept entry moves the current one into its place
+	pkg := state.Get("app")
+	state.Add("app", pkg.Previous[0])
+
+	pkg = state.Get("app")
+	if got, want := pkg.Versions(), []string{"1.0", "2.0"}; !slices.Equal(got, want) {
+		t.Errorf("Versions() = %v, want %v", got, want)
+	}
+	if pkg.Keep != 5 {
+		t.Errorf("Keep = %d, want it carried over", pkg.Keep)
+	}
+	if pkg.Channel != "rc" {
+		t.Errorf("Channel = %q, want it carried over", pkg.Channel)
+	}
+	if prev := pkg.Previous[0]; prev.Keep != 0 || prev.Constraint != "" || prev.Channel != "" || prev.Previous != nil {
+		t.Errorf("previous entry kept package-wide fields: %+v", prev)
+	}
+}
+
+func TestSetKeep(t *testing.T) {
+	state := &State{Packages: make(map[string]*Package)}
+	for _, v := range []string{"1", "2", "3"} {
+		state.Add("app", &Package{Version: v})
+	}
+
+	pkg := state.Get("app")
+	pkg.SetKeep(1)
+	if got := pkg.Versions(); !slices.Equal(got, []string{"3"}) {
+		t.Errorf("Versions() = %v, want only the active one", got)
+	}
+}
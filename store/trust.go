@@ -0,0 +1,142 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// Pin records the publisher key trusted for an app.
+type Pin struct {
+	Pubkey    string `json:"pubkey"`
+	TrustedAt string `json:"trusted_at"`
+}
+
+// Trust represents the contents of trust.json: the publisher key pinned for
+// each app on first install (trust on first use). Pins outlive the package
+// itself so that reinstalling a removed app is checked against the same key.
+type Trust struct {
+	Pins map[string]*Pin `json:"pins"`
+}
+
+// KeyChangedError is returned when an app is now published by a different
+// key than the one pinned for it.
+type KeyChangedError struct {
+	AppID  string
+	Pinned string // hex pubkey
+	Got    string // hex pubkey
+}
+
+func (e *KeyChangedError) Error() string {
+	return fmt.Sprintf("publisher key for %q changed from %s to %s; run 'zapstore trust %s' if this is expected",
+		e.AppID, Npub(e.Pinned), Npub(e.Got), e.AppID)
+}
+
+// Npub returns the bech32 npub encoding of a hex pubkey, or the hex itself
+// if it cannot be encoded.
+func Npub(pubkey string) string {
+	npub, err := nip19.EncodePublicKey(pubkey)
+	if err != nil {
+		return pubkey
+	}
+	return npub
+}
+
+// trustPath returns the path to trust.json.
+func trustPath() (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "trust.json"), nil
+}
+
+// LoadTrust reads the trust database from disk. Returns an empty database
+// if the file does not exist.
+func LoadTrust() (*Trust, error) {
+	p, err := trustPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Trust{Pins: make(map[string]*Pin)}, nil
+		}
+		return nil, fmt.Errorf("reading trust database: %w", err)
+	}
+
+	var t Trust
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("parsing trust database: %w", err)
+	}
+	if t.Pins == nil {
+		t.Pins = make(map[string]*Pin)
+	}
+	return &t, nil
+}
+
+// Save writes the trust database to disk, creating the directory if needed.
+func (t *Trust) Save() error {
+	p, err := trustPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling trust database: %w", err)
+	}
+
+	return WriteFileAtomic(p, data, 0o644)
+}
+
+// Seed pins the recorded publisher of every installed package that has no
+// pin yet, so installs made before the trust database existed are covered.
+func (t *Trust) Seed(s *State) {
+	for appID, pkg := range s.Packages {
+		if t.Get(appID) == nil && pkg.Pubkey != "" {
+			t.Pin(appID, pkg.Pubkey)
+		}
+	}
+}
+
+// Check returns a *KeyChangedError if appID is pinned to a key other than
+// pubkey. Unpinned apps always pass.
+func (t *Trust) Check(appID, pubkey string) error {
+	pin := t.Get(appID)
+	if pin == nil || pin.Pubkey == pubkey {
+		return nil
+	}
+	return &KeyChangedError{AppID: appID, Pinned: pin.Pubkey, Got: pubkey}
+}
+
+// Pin trusts pubkey as the publisher of appID, replacing any previous pin.
+func (t *Trust) Pin(appID, pubkey string) {
+	t.Pins[appID] = &Pin{
+		Pubkey:    pubkey,
+		TrustedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// Publisher returns the key pinned for appID, or "" if none is recorded.
+func (t *Trust) Publisher(appID string) string {
+	if pin := t.Get(appID); pin != nil {
+		return pin.Pubkey
+	}
+	return ""
+}
+
+// Get returns the pin for an app ID, or nil if none is recorded.
+func (t *Trust) Get(appID string) *Pin {
+	return t.Pins[appID]
+}
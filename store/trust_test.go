@@ -0,0 +1,44 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTrustCheck(t *testing.T) {
+	trust := &Trust{Pins: make(map[string]*Pin)}
+
+	if err := trust.Check("app", "aaaa"); err != nil {
+		t.Fatalf("unpinned app should pass, got %v", err)
+	}
+
+	trust.Pin("app", "aaaa")
+	if err := trust.Check("app", "aaaa"); err != nil {
+		t.Errorf("pinned key should pass, got %v", err)
+	}
+
+	var keyErr *KeyChangedError
+	if err := trust.Check("app", "bbbb"); !errors.As(err, &keyErr) {
+		t.Fatalf("expected *KeyChangedError, got %v", err)
+	}
+	if keyErr.Pinned != "aaaa" || keyErr.Got != "bbbb" {
+		t.Errorf("KeyChangedError = %+v", keyErr)
+	}
+}
+
+func TestTrustSeed(t *testing.T) {
+	state := &State{Packages: map[string]*Package{
+		"seeded": {Pubkey: "aaaa"},
+		"pinned": {Pubkey: "bbbb"},
+	}}
+	trust := &Trust{Pins: map[string]*Pin{"pinned": {Pubkey: "cccc"}}}
+
+	trust.Seed(state)
+
+	if pin := trust.Get("seeded"); pin == nil || pin.Pubkey != "aaaa" {
+		t.Errorf("expected installed package to be seeded, got %+v", pin)
+	}
+	if pin := trust.Get("pinned"); pin.Pubkey != "cccc" {
+		t.Errorf("existing pin must not be overwritten, got %s", pin.Pubkey)
+	}
+}
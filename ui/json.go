@@ -0,0 +1,39 @@
+package ui
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// JSON switches output to a machine-readable form, set by the global --json
+// flag. Commands then write one document to stdout with PrintJSON; status
+// lines, tables, spinners and progress bars are suppressed, and errors and
+// warnings go to stderr.
+var JSON = false
+
+// jsonWritten records whether PrintJSON has been called.
+var jsonWritten bool
+
+// PrintJSON writes v to stdout as indented JSON.
+func PrintJSON(v any) error {
+	jsonWritten = true
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+// JSONWritten reports whether a JSON document has been written to stdout.
+func JSONWritten() bool {
+	return jsonWritten
+}
+
+// statusWriter returns where spinners and progress bars draw: stderr, or
+// nowhere in JSON mode.
+func statusWriter() io.Writer {
+	if JSON {
+		return io.Discard
+	}
+	return os.Stderr
+}
@@ -0,0 +1,210 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MultiProgress shows one row per item, such as the packages of an update,
+// and redraws them in place while work on several of them runs at once.
+// Each row shows a status message or, during a transfer, a progress bar.
+type MultiProgress struct {
+	rows   []*Row
+	frames []string
+	index  int
+	drawn  int // lines written by the last redraw
+	done   chan struct{}
+	wg     sync.WaitGroup
+	writer io.Writer
+	active bool
+	mu     sync.Mutex
+}
+
+// Row is one line of a MultiProgress. It has the same methods as Progress,
+// so a download can report to either, plus Status for other stages. Its
+// methods may be called from any goroutine.
+type Row struct {
+	m       *MultiProgress
+	label   string
+	message string
+	state   rowState
+	total   int64
+	current int64
+	resumed int64
+	started time.Time
+}
+
+type rowState int
+
+const (
+	rowWaiting  rowState = iota // not started yet
+	rowBusy                     // working, described by message
+	rowTransfer                 // transferring bytes
+	rowDone
+	rowFailed
+)
+
+// NewMultiProgress creates an empty MultiProgress.
+func NewMultiProgress() *MultiProgress {
+	frames := DefaultFrames
+	if NoColor {
+		frames = SimpleFrames
+	}
+	return &MultiProgress{
+		frames: frames,
+		writer: statusWriter(),
+		done:   make(chan struct{}),
+	}
+}
+
+// Add appends a row for label, showing message until it is updated.
+func (m *MultiProgress) Add(label, message string) *Row {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := &Row{m: m, label: label, message: message}
+	m.rows = append(m.rows, r)
+	return r
+}
+
+// Start begins redrawing the rows.
+func (m *MultiProgress) Start() {
+	m.mu.Lock()
+	if m.active {
+		m.mu.Unlock()
+		return
+	}
+	m.active = true
+	m.done = make(chan struct{})
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.done:
+				return
+			case <-ticker.C:
+				m.mu.Lock()
+				m.index = (m.index + 1) % len(m.frames)
+				m.redraw()
+				m.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// Stop stops redrawing, leaving the rows on screen in their final state.
+func (m *MultiProgress) Stop() {
+	m.mu.Lock()
+	if !m.active {
+		m.mu.Unlock()
+		return
+	}
+	m.active = false
+	close(m.done)
+	m.mu.Unlock()
+
+	m.wg.Wait()
+	m.mu.Lock()
+	m.redraw()
+	m.mu.Unlock()
+}
+
+// redraw rewrites every row over the previous drawing. Callers hold m.mu.
+func (m *MultiProgress) redraw() {
+	width := 0
+	for _, r := range m.rows {
+		width = max(width, len(r.label))
+	}
+
+	var b strings.Builder
+	if m.drawn > 0 {
+		fmt.Fprintf(&b, "\033[%dA", m.drawn)
+	}
+	for _, r := range m.rows {
+		fmt.Fprintf(&b, "\r\033[K%s %-*s  %s\n", r.icon(m.frames[m.index]), width, r.label, r.text())
+	}
+	m.drawn = len(m.rows)
+	io.WriteString(m.writer, b.String())
+}
+
+func (r *Row) icon(frame string) string {
+	switch r.state {
+	case rowWaiting:
+		return Dim(FallbackIcon(IconDot, "."))
+	case rowDone:
+		return Checkmark()
+	case rowFailed:
+		return Cross()
+	}
+	return InfoStyle.Render(frame)
+}
+
+func (r *Row) text() string {
+	switch r.state {
+	case rowWaiting:
+		return Dim(r.message)
+	case rowTransfer:
+		return transferStatus(r.current, r.total, r.resumed, time.Since(r.started).Seconds())
+	}
+	return r.message
+}
+
+// Status shows message while the item is being worked on.
+func (r *Row) Status(message string) {
+	r.set(rowBusy, message)
+}
+
+// Write records len(p) transferred bytes.
+func (r *Row) Write(b []byte) (int, error) {
+	r.m.mu.Lock()
+	r.current += int64(len(b))
+	r.m.mu.Unlock()
+	return len(b), nil
+}
+
+// SetTotal updates the expected size of the transfer; 0 if unknown.
+func (r *Row) SetTotal(total int64) {
+	r.m.mu.Lock()
+	r.total = total
+	r.m.mu.Unlock()
+}
+
+// Add records n bytes transferred outside of Write, as for Progress.Add.
+func (r *Row) Add(n int64) {
+	r.m.mu.Lock()
+	r.current += n
+	r.resumed += n
+	r.m.mu.Unlock()
+}
+
+// Start switches the row to showing the transfer's progress.
+func (r *Row) Start() {
+	r.m.mu.Lock()
+	r.state = rowTransfer
+	r.started = time.Now()
+	r.m.mu.Unlock()
+}
+
+// StopWithSuccess marks the item as done with a message.
+func (r *Row) StopWithSuccess(message string) {
+	r.set(rowDone, message)
+}
+
+// StopWithError marks the item as failed with a message.
+func (r *Row) StopWithError(message string) {
+	r.set(rowFailed, message)
+}
+
+func (r *Row) set(state rowState, message string) {
+	r.m.mu.Lock()
+	r.state, r.message = state, message
+	r.m.mu.Unlock()
+}
@@ -0,0 +1,116 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// plainMultiProgress returns a MultiProgress drawing without color into buf.
+func plainMultiProgress(t *testing.T, buf *bytes.Buffer) *MultiProgress {
+	t.Helper()
+	NoColor = true
+	initStyles()
+	t.Cleanup(func() {
+		NoColor = false
+		initStyles()
+	})
+	m := NewMultiProgress()
+	m.writer = buf
+	return m
+}
+
+func TestMultiProgressRedraw(t *testing.T) {
+	var buf bytes.Buffer
+	m := plainMultiProgress(t, &buf)
+
+	m.Add("com.example.tool", "Waiting")
+	m.Add("jq", "Waiting").Status("Installing")
+	m.Add("ripgrep", "Waiting").StopWithSuccess("v1 -> v2")
+	m.Add("fd", "Waiting").StopWithError("hash mismatch")
+	dl := m.Add("bat", "Waiting")
+	dl.Start()
+	dl.SetTotal(2048)
+	dl.Add(512) // resumed
+	dl.Write(make([]byte, 512))
+
+	m.redraw()
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	want := []string{
+		". com.example.tool  Waiting",
+		"| jq                Installing",
+		"[OK] ripgrep           v1 -> v2",
+		"[ERROR] fd                hash mismatch",
+		"| bat               ",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("drew %d lines, want %d:\n%s", len(lines), len(want), buf.String())
+	}
+	for i, line := range lines {
+		line = strings.TrimPrefix(line, "\r\033[K")
+		if !strings.HasPrefix(line, want[i]) {
+			t.Errorf("line %d = %q, want it to start with %q", i, line, want[i])
+		}
+	}
+	if !strings.Contains(lines[4], " 50%  1.0 KB / 2.0 KB") {
+		t.Errorf("transfer row = %q, want half of 2.0 KB done", lines[4])
+	}
+
+	// A redraw moves back up over the rows it replaces
+	buf.Reset()
+	m.redraw()
+	if !strings.HasPrefix(buf.String(), "\033[5A") {
+		t.Errorf("redraw starts with %q, want the cursor moved up 5 lines", buf.String()[:min(8, buf.Len())])
+	}
+}
+
+func TestMultiProgressConcurrentRows(t *testing.T) {
+	var buf bytes.Buffer
+	m := plainMultiProgress(t, &buf)
+	rows := make([]*Row, 8)
+	for i := range rows {
+		rows[i] = m.Add(fmt.Sprintf("app%d", i), "Waiting")
+	}
+
+	// Rows are updated from worker goroutines while the display redraws
+	m.Start()
+	var wg sync.WaitGroup
+	for i, r := range rows {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Status("Downloading")
+			r.Start()
+			r.SetTotal(1000)
+			for range 100 {
+				r.Write(make([]byte, 10))
+			}
+			r.Status("Installing")
+			if i%2 == 0 {
+				r.StopWithSuccess("done")
+			} else {
+				r.StopWithError("failed")
+			}
+		}()
+	}
+	wg.Wait()
+	m.Stop()
+	m.Stop() // a second Stop is a no-op
+
+	// The final drawing shows every row's outcome
+	last := buf.String()
+	if i := strings.LastIndex(last, "\033[8A"); i >= 0 {
+		last = last[i:]
+	}
+	for i := range rows {
+		want := fmt.Sprintf("[OK] app%d  done", i)
+		if i%2 == 1 {
+			want = fmt.Sprintf("[ERROR] app%d  failed", i)
+		}
+		if !strings.Contains(last, want) {
+			t.Errorf("final drawing lacks %q:\n%s", want, last)
+		}
+	}
+}
@@ -2,11 +2,16 @@ package ui
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
 )
 
 // Header prints a section header line.
 func Header(title string) {
+	if JSON {
+		return
+	}
 	if NoColor {
 		fmt.Printf("=== %s ===\n", strings.ToUpper(title))
 		return
@@ -19,8 +24,25 @@ func Header(title string) {
 
 // Statusf prints a status line with an icon and formatted message.
 func Statusf(icon, format string, args ...any) {
+	if JSON {
+		return
+	}
+	fprintStatus(os.Stdout, icon, format, args...)
+}
+
+// problemf prints an error or warning line. In JSON mode it goes to stderr,
+// so problems stay visible without mixing into the document on stdout.
+func problemf(icon, format string, args ...any) {
+	if JSON {
+		fprintStatus(os.Stderr, icon, format, args...)
+		return
+	}
+	fprintStatus(os.Stdout, icon, format, args...)
+}
+
+func fprintStatus(w io.Writer, icon, format string, args ...any) {
 	msg := fmt.Sprintf(format, args...)
-	fmt.Printf("  %s %s\n", icon, msg)
+	fmt.Fprintf(w, "  %s %s\n", icon, msg)
 }
 
 // Successf prints a success line.
@@ -30,28 +52,43 @@ func Successf(format string, args ...any) {
 
 // Errorf prints an error line.
 func Errorf(format string, args ...any) {
-	Statusf(Cross(), format, args...)
+	problemf(Cross(), format, args...)
 }
 
 // Warningf prints a warning line.
 func Warningf(format string, args ...any) {
-	Statusf(Warn(), format, args...)
+	problemf(Warn(), format, args...)
+}
+
+// StderrWarningf prints a warning line to stderr, for problems reported
+// while spinners are drawing there or while stdout may be piped.
+func StderrWarningf(format string, args ...any) {
+	fprintStatus(os.Stderr, Warn(), format, args...)
 }
 
 // Infof prints an informational line.
 func Infof(format string, args ...any) {
+	if JSON {
+		return
+	}
 	msg := fmt.Sprintf(format, args...)
 	fmt.Printf("  %s %s\n", Info(IconDot), msg)
 }
 
 // Resultf prints a final result line (e.g. "Installed foo → ~/.zapstore/bin/foo").
 func Resultf(format string, args ...any) {
+	if JSON {
+		return
+	}
 	msg := fmt.Sprintf(format, args...)
 	fmt.Printf("\n  %s %s\n\n", Checkmark(), BoldStyle.Render(msg))
 }
 
 // TableHeader prints a formatted table header row.
 func TableHeader(widths []int, names ...string) {
+	if JSON {
+		return
+	}
 	var header, sep strings.Builder
 	for i, name := range names {
 		w := widths[i]
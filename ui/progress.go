@@ -0,0 +1,170 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressWidth is the number of cells in the progress bar.
+const progressWidth = 24
+
+// Progress displays a byte-count progress bar for transfers. It implements
+// io.Writer so it can sit in an io.MultiWriter next to the destination.
+type Progress struct {
+	message string
+	total   int64 // 0 if unknown
+	current int64
+	resumed int64 // bytes counted via Add, excluded from throughput
+	started time.Time
+	done    chan struct{}
+	wg      sync.WaitGroup
+	writer  io.Writer
+	active  bool
+	mu      sync.Mutex
+}
+
+// NewProgress creates a progress bar for a transfer of total bytes.
+// Pass 0 if the size is unknown; only bytes and throughput are shown then.
+func NewProgress(message string, total int64) *Progress {
+	return &Progress{
+		message: message,
+		total:   total,
+		writer:  statusWriter(),
+		done:    make(chan struct{}),
+	}
+}
+
+// Write records len(p) transferred bytes.
+func (p *Progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	p.current += int64(len(b))
+	p.mu.Unlock()
+	return len(b), nil
+}
+
+// SetTotal updates the expected size, e.g. once Content-Length is known.
+func (p *Progress) SetTotal(total int64) {
+	p.mu.Lock()
+	p.total = total
+	p.mu.Unlock()
+}
+
+// Add records n bytes transferred outside of Write, such as the part of a
+// resumed download that was already on disk. n may be negative to undo it.
+func (p *Progress) Add(n int64) {
+	p.mu.Lock()
+	p.current += n
+	p.resumed += n
+	p.mu.Unlock()
+}
+
+// Start begins redrawing the progress bar.
+func (p *Progress) Start() {
+	p.mu.Lock()
+	if p.active {
+		p.mu.Unlock()
+		return
+	}
+	p.active = true
+	p.started = time.Now()
+	p.done = make(chan struct{})
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+				fmt.Fprintf(p.writer, "\r\033[K%s", p.render())
+			}
+		}
+	}()
+}
+
+// Stop stops redrawing and clears the line.
+func (p *Progress) Stop() {
+	p.mu.Lock()
+	if !p.active {
+		p.mu.Unlock()
+		return
+	}
+	p.active = false
+	close(p.done)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	fmt.Fprintf(p.writer, "\r\033[K")
+}
+
+// StopWithSuccess stops the progress bar with a success message.
+func (p *Progress) StopWithSuccess(message string) {
+	p.Stop()
+	fmt.Fprintf(p.writer, "%s %s\n", Checkmark(), message)
+}
+
+// StopWithError stops the progress bar with an error message.
+func (p *Progress) StopWithError(message string) {
+	p.Stop()
+	fmt.Fprintf(p.writer, "%s %s\n", Cross(), message)
+}
+
+func (p *Progress) render() string {
+	p.mu.Lock()
+	current, total, resumed, msg := p.current, p.total, p.resumed, p.message
+	elapsed := time.Since(p.started).Seconds()
+	p.mu.Unlock()
+
+	return msg + "  " + transferStatus(current, total, resumed, elapsed)
+}
+
+// transferStatus renders a transfer of current out of total bytes (0 if
+// unknown) as a bar, percentage, sizes and throughput. resumed bytes were
+// already there and do not count towards throughput.
+func transferStatus(current, total, resumed int64, elapsed float64) string {
+	var rate string
+	if elapsed > 0 {
+		rate = FormatBytes(int64(float64(current-resumed)/elapsed)) + "/s"
+	}
+
+	if total <= 0 {
+		return fmt.Sprintf("%s  %s", FormatBytes(current), Dim(rate))
+	}
+
+	frac := float64(current) / float64(total)
+	if frac > 1 {
+		frac = 1
+	}
+	filled := int(frac * progressWidth)
+
+	fill, empty := "█", "░"
+	if NoColor {
+		fill, empty = "#", "-"
+	}
+	bar := Info(strings.Repeat(fill, filled)) + Dim(strings.Repeat(empty, progressWidth-filled))
+
+	return fmt.Sprintf("%s %3d%%  %s / %s  %s",
+		bar, int(frac*100), FormatBytes(current), FormatBytes(total), Dim(rate))
+}
+
+// FormatBytes formats a byte count in binary units, such as 1.5 MB.
+func FormatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -3,7 +3,6 @@ package ui
 import (
 	"fmt"
 	"io"
-	"os"
 	"sync"
 	"time"
 )
@@ -35,7 +34,7 @@ func NewSpinner(message string) *Spinner {
 	return &Spinner{
 		message: message,
 		frames:  frames,
-		writer:  os.Stderr,
+		writer:  statusWriter(),
 		done:    make(chan struct{}),
 	}
 }
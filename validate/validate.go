@@ -0,0 +1,85 @@
+// Package validate checks names taken from Nostr events or the command line
+// before they are used to build filesystem paths.
+//
+// App IDs, versions and file names all become single path segments under
+// the data directory, so each must be non-empty, bounded in length, free of
+// path separators and dot segments, and drawn from a conservative character
+// set.
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsafe is wrapped by every validation error, so callers can detect a
+// rejected name with errors.Is regardless of which field failed.
+var ErrUnsafe = errors.New("security")
+
+// Error describes a rejected name.
+type Error struct {
+	Field  string // "app ID", "version", "file name"
+	Value  string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("security: refusing %s %q: %s", e.Field, e.Value, e.Reason)
+}
+
+func (e *Error) Unwrap() error { return ErrUnsafe }
+
+// Length limits.
+const (
+	maxAppID    = 255
+	maxVersion  = 128
+	maxFileName = 255
+)
+
+// AppID checks a reverse-domain app identifier such as com.github.jqlang.jq.
+func AppID(s string) error {
+	return segment("app ID", s, maxAppID, "._-")
+}
+
+// Version checks a release version such as v1.2.3-rc.1+build.5.
+func Version(s string) error {
+	return segment("version", s, maxVersion, "._-+~")
+}
+
+// FileName checks an executable or asset file name. It must be a bare name:
+// no directories, and not hidden.
+func FileName(s string) error {
+	return segment("file name", s, maxFileName, "._-+@")
+}
+
+// segment checks that s is a single, safe path segment of at most max bytes
+// made of ASCII letters, digits, and the given punctuation.
+func segment(field, s string, max int, punct string) error {
+	fail := func(reason string) error {
+		return &Error{Field: field, Value: s, Reason: reason}
+	}
+
+	switch {
+	case s == "":
+		return fail("empty")
+	case len(s) > max:
+		return fail(fmt.Sprintf("longer than %d characters", max))
+	case s == "." || s == "..":
+		return fail("dot segment")
+	case s[0] == '.' || s[0] == '-':
+		return fail(fmt.Sprintf("starts with %q", s[0]))
+	}
+
+	for _, r := range s {
+		switch {
+		case r == '/' || r == '\\':
+			return fail("contains a path separator")
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r < 0x80 && strings.IndexByte(punct, byte(r)) != -1:
+		default:
+			return fail(fmt.Sprintf("contains %q", r))
+		}
+	}
+	return nil
+}
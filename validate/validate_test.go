@@ -0,0 +1,55 @@
+package validate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAppID(t *testing.T) {
+	valid := []string{"com.github.jqlang.jq", "yt-dlp", "tool_2"}
+	invalid := []string{"", ".", "..", "../x", "a/b", `a\b`, ".hidden", "-flag", "a b", "app\x00", "ünicode", strings.Repeat("a", 256)}
+
+	for _, s := range valid {
+		if err := AppID(s); err != nil {
+			t.Errorf("AppID(%q) = %v, want nil", s, err)
+		}
+	}
+	for _, s := range invalid {
+		if err := AppID(s); !errors.Is(err, ErrUnsafe) {
+			t.Errorf("AppID(%q) = %v, want ErrUnsafe", s, err)
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+	valid := []string{"1.2.3", "v1.2.3-rc.1+build.5", "2024.05.14", "1.0~beta"}
+	invalid := []string{"", "..", "../x", "1.0/2", ".1", strings.Repeat("1", 129)}
+
+	for _, s := range valid {
+		if err := Version(s); err != nil {
+			t.Errorf("Version(%q) = %v, want nil", s, err)
+		}
+	}
+	for _, s := range invalid {
+		if err := Version(s); !errors.Is(err, ErrUnsafe) {
+			t.Errorf("Version(%q) = %v, want ErrUnsafe", s, err)
+		}
+	}
+}
+
+func TestFileName(t *testing.T) {
+	valid := []string{"jq", "tool-linux-x86_64.tar.gz", "tool.exe", "c++filt"}
+	invalid := []string{"", ".", "..", "../../../.bashrc", ".bashrc", "bin/tool", "tool\n", "tool;rm"}
+
+	for _, s := range valid {
+		if err := FileName(s); err != nil {
+			t.Errorf("FileName(%q) = %v, want nil", s, err)
+		}
+	}
+	for _, s := range invalid {
+		if err := FileName(s); !errors.Is(err, ErrUnsafe) {
+			t.Errorf("FileName(%q) = %v, want ErrUnsafe", s, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package version
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Release channels. A version without a pre-release suffix is on the stable
+// channel; otherwise its channel is the leading word of the suffix: rc for
+// 2.0.0-rc.1, beta for 1.4-beta2, pre for 1.0.0-1.
+const (
+	Stable = "stable"
+	AnyPre = "pre" // follows every channel
+)
+
+// Channel returns the release channel implied by a version string.
+func Channel(v string) string {
+	pv := parse(v)
+	if len(pv.preRelease) == 0 {
+		return Stable
+	}
+	word := strings.TrimRightFunc(pv.preRelease[0].value, func(r rune) bool {
+		return r >= '0' && r <= '9'
+	})
+	if word == "" {
+		return AnyPre
+	}
+	return strings.ToLower(word)
+}
+
+// InChannel reports whether a release on channel release may be picked by
+// someone following channel follow. Stable releases are on every channel,
+// AnyPre takes everything, and any other channel also takes releases on
+// exactly that channel.
+func InChannel(release, follow string) bool {
+	return release == Stable || follow == AnyPre || release == follow
+}
+
+// ParseChannel checks a channel name given by the user and returns it
+// lowercased. Names are a single word of letters, as derived by Channel.
+func ParseChannel(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", fmt.Errorf("empty channel name")
+	}
+	for _, r := range name {
+		if r < 'a' || r > 'z' {
+			return "", fmt.Errorf("invalid channel %q: use letters only, such as stable, beta or rc", name)
+		}
+	}
+	return name, nil
+}
@@ -0,0 +1,53 @@
+package version
+
+import "testing"
+
+func TestChannel(t *testing.T) {
+	tests := map[string]string{
+		"1.0.0":           Stable,
+		"v2.1+build.5":    Stable,
+		"2.0.0-rc.1":      "rc",
+		"1.4-beta2":       "beta",
+		"1.4.0-Alpha":     "alpha",
+		"1.0.0-1":         AnyPre,
+		"3.0.0-nightly.7": "nightly",
+	}
+	for v, want := range tests {
+		if got := Channel(v); got != want {
+			t.Errorf("Channel(%q) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestInChannel(t *testing.T) {
+	tests := []struct {
+		release, follow string
+		want            bool
+	}{
+		{Stable, Stable, true},
+		{"rc", Stable, false},
+		{Stable, "beta", true},
+		{"beta", "beta", true},
+		{"rc", "beta", false},
+		{"rc", AnyPre, true},
+		{"nightly", AnyPre, true},
+	}
+	for _, tt := range tests {
+		if got := InChannel(tt.release, tt.follow); got != tt.want {
+			t.Errorf("InChannel(%q, %q) = %v, want %v", tt.release, tt.follow, got, tt.want)
+		}
+	}
+}
+
+func TestParseChannel(t *testing.T) {
+	for in, want := range map[string]string{"stable": Stable, "Beta": "beta", " rc ": "rc"} {
+		if got, err := ParseChannel(in); err != nil || got != want {
+			t.Errorf("ParseChannel(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "rc1", "beta-2", "a b"} {
+		if _, err := ParseChannel(in); err == nil {
+			t.Errorf("ParseChannel(%q) succeeded, want error", in)
+		}
+	}
+}
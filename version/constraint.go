@@ -0,0 +1,354 @@
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Constraint is a parsed version requirement. Supported forms:
+//
+//	1.2.3, =1.2.3     exactly that version (1.2 and 1.2.0 are equal)
+//	^1.2.3            compatible: >=1.2.3 <2.0.0 (^0.2.3 is <0.3.0, ^0.0.3 is <0.0.4)
+//	~1.2.3            patch updates: >=1.2.3 <1.3.0 (~1 is <2.0.0)
+//	1.x, 1.2.*, *     wildcards
+//	>=1.0 <2.0        comparisons (>, >=, <, <=), space- or comma-separated, all must hold
+//	^1.2 || ^2.0      alternatives, any may hold
+//
+// Upper bounds like the <2.0.0 in ^1.2.3 also exclude pre-releases of
+// that bound (2.0.0-rc.1), as does an explicit <2.0. Pre-releases within
+// the range match; filtering them out is up to the caller.
+type Constraint struct {
+	raw     string
+	alts    [][]comparator
+	literal bool // raw is a version matched as written, see ParseSpec
+}
+
+// ParseConstraint parses a version constraint.
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{raw: strings.TrimSpace(s)}
+	for _, alt := range strings.Split(c.raw, "||") {
+		comps, err := parseAlternative(alt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraint %q: %w", s, err)
+		}
+		c.alts = append(c.alts, comps)
+	}
+	return c, nil
+}
+
+// ParseSpec parses a version as given to install or pin: a constraint as
+// for ParseConstraint, or else a single version that is not numeric enough
+// to be one, such as r123 or 1.0~beta. Such a version only matches itself,
+// as written, since Compare cannot tell it apart from its neighbours. A
+// spec starting with an operator or holding several terms must parse as a
+// constraint.
+func ParseSpec(s string) (*Constraint, error) {
+	c, err := ParseConstraint(s)
+	if err == nil {
+		return c, nil
+	}
+	raw := strings.TrimSpace(s)
+	if raw == "" || strings.ContainsAny(raw[:1], "^~<>=*") || strings.ContainsAny(raw, " \t,|") {
+		return nil, err
+	}
+	return &Constraint{raw: raw, literal: true}, nil
+}
+
+// Check reports whether v satisfies the constraint.
+func (c *Constraint) Check(v string) bool {
+	if c.literal {
+		return v == c.raw
+	}
+	pv := parse(v)
+	for _, alt := range c.alts {
+		ok := true
+		for _, comp := range alt {
+			if !comp.check(pv) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExact reports whether the constraint names a single version rather than
+// a range.
+func (c *Constraint) IsExact() bool {
+	if c.literal {
+		return true
+	}
+	return len(c.alts) == 1 && len(c.alts[0]) == 1 && c.alts[0][0].op == opEQ
+}
+
+// IsAny reports whether every version satisfies the constraint, as with *.
+func (c *Constraint) IsAny() bool {
+	for _, alt := range c.alts {
+		if len(alt) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the constraint as it was written.
+func (c *Constraint) String() string {
+	return c.raw
+}
+
+// Equal reports whether a and b name the same version: identical as
+// written, or both numeric versions that Compare as equal (1.2 and v1.2.0).
+// Versions that are not numeric, such as r123 or 1.0~beta, only equal
+// themselves, since Compare cannot tell them apart from their neighbours.
+func Equal(a, b string) bool {
+	if a == b {
+		return true
+	}
+	return isNumeric(a) && isNumeric(b) && Compare(a, b) == 0
+}
+
+// isNumeric reports whether v is a complete version made of numbers, with
+// an optional pre-release, as ParseConstraint accepts.
+func isNumeric(v string) bool {
+	p, err := parsePartial(v)
+	return err == nil && !p.wild && len(p.parts) > 0
+}
+
+// Satisfies reports whether v satisfies constraint.
+func Satisfies(v, constraint string) (bool, error) {
+	c, err := ParseConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+	return c.Check(v), nil
+}
+
+// MaxSatisfying returns the highest of versions that satisfies constraint,
+// or "" if none does. Among equal versions the first one wins.
+func MaxSatisfying(versions []string, constraint string) (string, error) {
+	c, err := ParseConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+	best := ""
+	for _, v := range versions {
+		if c.Check(v) && (best == "" || Compare(v, best) > 0) {
+			best = v
+		}
+	}
+	return best, nil
+}
+
+// --------------------------------------------------------------------------
+// Internal
+// --------------------------------------------------------------------------
+
+type op int
+
+const (
+	opEQ op = iota
+	opGT
+	opGE
+	opLT
+	opLE
+	opBelow // less than every release of the bound, pre-releases included
+)
+
+type comparator struct {
+	op op
+	v  version
+}
+
+func (c comparator) check(v version) bool {
+	switch c.op {
+	case opEQ:
+		return v.cmp(c.v) == 0
+	case opGT:
+		return v.cmp(c.v) > 0
+	case opGE:
+		return v.cmp(c.v) >= 0
+	case opLT:
+		return v.cmp(c.v) < 0
+	case opLE:
+		return v.cmp(c.v) <= 0
+	case opBelow:
+		return version{parts: v.parts}.cmp(c.v) < 0
+	}
+	return false
+}
+
+// partial is a version as written in a constraint, where trailing parts may
+// be missing or wildcards.
+type partial struct {
+	parts []int  // the numeric parts before any wildcard
+	pre   string // pre-release, only allowed on complete versions
+	wild  bool   // ended in x, X or *
+}
+
+func (p partial) version() version {
+	v := version{parts: p.parts}
+	if len(v.parts) == 0 {
+		v.parts = []int{0}
+	}
+	if p.pre != "" {
+		for _, id := range strings.Split(p.pre, ".") {
+			v.preRelease = append(v.preRelease, newIdentifier(id))
+		}
+	}
+	return v
+}
+
+// bump returns the version just past p at index i: bump([1 2 3], 1) is 1.3.
+func (p partial) bump(i int) version {
+	parts := append([]int(nil), p.parts[:i+1]...)
+	parts[i]++
+	return version{parts: parts}
+}
+
+func parseAlternative(s string) ([]comparator, error) {
+	// Join operators separated from their version: ">= 1.0" → ">=1.0"
+	var tokens []string
+	pending := ""
+	for _, f := range strings.Fields(strings.ReplaceAll(s, ",", " ")) {
+		if strings.Trim(f, "<>=^~") == "" {
+			pending += f
+			continue
+		}
+		tokens = append(tokens, pending+f)
+		pending = ""
+	}
+	if pending != "" {
+		return nil, fmt.Errorf("operator %q without a version", pending)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty constraint")
+	}
+
+	var comps []comparator
+	for _, tok := range tokens {
+		c, err := parseTerm(tok)
+		if err != nil {
+			return nil, err
+		}
+		comps = append(comps, c...)
+	}
+	return comps, nil
+}
+
+// parseTerm expands one operator and version into plain comparators.
+func parseTerm(tok string) ([]comparator, error) {
+	operator := ""
+	for _, o := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(tok, o) {
+			operator = o
+			break
+		}
+	}
+	p, err := parsePartial(strings.TrimPrefix(tok, operator))
+	if err != nil {
+		return nil, err
+	}
+	n := len(p.parts)
+
+	switch operator {
+	case "", "=":
+		if !p.wild {
+			return []comparator{{opEQ, p.version()}}, nil
+		}
+		if n == 0 {
+			return nil, nil // any version
+		}
+		return []comparator{{opGE, p.version()}, {opBelow, p.bump(n - 1)}}, nil
+
+	case "^":
+		if n == 0 {
+			return nil, nil
+		}
+		// Bump the first non-zero part, or the last given one if all are zero
+		i := n - 1
+		for j, part := range p.parts {
+			if part != 0 {
+				i = j
+				break
+			}
+		}
+		return []comparator{{opGE, p.version()}, {opBelow, p.bump(i)}}, nil
+
+	case "~":
+		if n == 0 {
+			return nil, nil
+		}
+		return []comparator{{opGE, p.version()}, {opBelow, p.bump(min(1, n-1))}}, nil
+
+	case ">=":
+		return []comparator{{opGE, p.version()}}, nil
+
+	case ">":
+		if p.wild {
+			if n == 0 {
+				return nil, fmt.Errorf("%q matches nothing", tok)
+			}
+			return []comparator{{opGE, p.bump(n - 1)}}, nil
+		}
+		return []comparator{{opGT, p.version()}}, nil
+
+	case "<":
+		if p.pre != "" {
+			return []comparator{{opLT, p.version()}}, nil
+		}
+		return []comparator{{opBelow, p.version()}}, nil
+
+	case "<=":
+		if p.wild {
+			if n == 0 {
+				return nil, nil
+			}
+			return []comparator{{opBelow, p.bump(n - 1)}}, nil
+		}
+		return []comparator{{opLE, p.version()}}, nil
+	}
+	return nil, fmt.Errorf("unknown operator in %q", tok)
+}
+
+// parsePartial parses a version that may end in wildcards: 1, 1.2.x, *.
+// Unlike parse, it rejects anything that is not a number or wildcard.
+func parsePartial(s string) (partial, error) {
+	orig := s
+	s = strings.TrimPrefix(s, "v")
+	s = strings.TrimPrefix(s, "V")
+	if i := strings.Index(s, "+"); i != -1 {
+		s = s[:i]
+	}
+
+	var p partial
+	core := s
+	if i := strings.Index(s, "-"); i != -1 {
+		core, p.pre = s[:i], s[i+1:]
+		if p.pre == "" {
+			return p, fmt.Errorf("empty pre-release in %q", orig)
+		}
+	}
+
+	for _, part := range strings.Split(core, ".") {
+		switch {
+		case part == "x" || part == "X" || part == "*":
+			p.wild = true
+		case p.wild:
+			return p, fmt.Errorf("number after wildcard in %q", orig)
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil || n < 0 {
+				return p, fmt.Errorf("invalid version %q", orig)
+			}
+			p.parts = append(p.parts, n)
+		}
+	}
+	if p.wild && p.pre != "" {
+		return p, fmt.Errorf("pre-release on wildcard version %q", orig)
+	}
+	return p, nil
+}
@@ -0,0 +1,208 @@
+package version
+
+import "testing"
+
+func TestSatisfies(t *testing.T) {
+	tests := []struct {
+		constraint string
+		v          string
+		want       bool
+	}{
+		// exact
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "v1.2.3", true},
+		{"=1.2", "1.2.0", true},
+		{"1.2.3", "1.2.4", false},
+		{"1.0.0-rc.1", "1.0.0-rc.1", true},
+		{"1.0.0-rc.1", "1.0.0", false},
+
+		// caret
+		{"^1.2.3", "1.2.3", true},
+		{"^1.2.3", "1.9.0", true},
+		{"^1.2.3", "1.2.2", false},
+		{"^1.2.3", "2.0.0", false},
+		{"^1.2.3", "2.0.0-rc.1", false}, // excluded with its release
+		{"^1.2", "1.10", true},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.3", true},
+		{"^0.0.3", "0.0.4", false},
+		{"^0", "0.9", true},
+		{"^0", "1.0", false},
+		{"^1.2.3-beta", "1.2.3-beta.2", true},
+
+		// tilde
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"~1.2", "1.2.0", true},
+		{"~1", "1.9", true},
+		{"~1", "2.0", false},
+
+		// wildcards
+		{"1.x", "1.0.0", true},
+		{"1.x", "1.99.3", true},
+		{"1.x", "2.0.0", false},
+		{"1.x", "0.9", false},
+		{"1.2.*", "1.2.7", true},
+		{"1.2.*", "1.3.0", false},
+		{"*", "0.0.1", true},
+		{"x", "2024.10.1", true},
+
+		// comparisons and ranges
+		{">=1.0 <2.0", "1.5", true},
+		{">=1.0 <2.0", "2.0", false},
+		{">=1.0 <2.0", "2.0.0-alpha", false},
+		{">=1.0 <2.0", "0.9", false},
+		{">= 1.0, < 2.0", "1.0", true},
+		{">1.2.3", "1.2.3", false},
+		{">1.2.3", "1.2.4", true},
+		{">1.x", "1.9", false},
+		{">1.x", "2.0", true},
+		{"<=1.2.3", "1.2.3", true},
+		{"<=1.x", "1.9", true},
+		{"<=1.x", "2.0", false},
+		{"<2.0.0-rc.2", "2.0.0-rc.1", true},
+
+		// alternatives
+		{"^1.2 || ^3", "3.1", true},
+		{"^1.2 || ^3", "2.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint+"/"+tt.v, func(t *testing.T) {
+			got, err := Satisfies(tt.v, tt.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Satisfies(%q, %q) = %v, want %v", tt.v, tt.constraint, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, c := range []string{"", "abc", "^", ">=", "1.x.2", "1.x-rc", "1.2-", "^1 ||", ">*"} {
+		if _, err := ParseConstraint(c); err == nil {
+			t.Errorf("ParseConstraint(%q) should fail", c)
+		}
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		spec, v     string
+		exact, want bool
+	}{
+		{"^1.2", "1.3.0", false, true},
+		{"1.2", "1.2.0", true, true},
+		{"r123", "r123", true, true},
+		{"r123", "r124", true, false}, // equal to Compare, but not the same release
+		{"1.0~beta", "1.0~beta", true, true},
+		{"1.0~beta", "1.0", true, false},
+	}
+	for _, tt := range tests {
+		c, err := ParseSpec(tt.spec)
+		if err != nil {
+			t.Errorf("ParseSpec(%q): %v", tt.spec, err)
+			continue
+		}
+		if c.IsExact() != tt.exact {
+			t.Errorf("ParseSpec(%q).IsExact() = %v, want %v", tt.spec, !tt.exact, tt.exact)
+		}
+		if got := c.Check(tt.v); got != tt.want {
+			t.Errorf("ParseSpec(%q).Check(%q) = %v, want %v", tt.spec, tt.v, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "^", "^r1", ">=abc", "r1 || r2", "r1,r2", "*x"} {
+		if _, err := ParseSpec(spec); err == nil {
+			t.Errorf("ParseSpec(%q) should fail", spec)
+		}
+	}
+}
+
+func TestIsExact(t *testing.T) {
+	tests := map[string]bool{
+		"1.2.3":      true,
+		"=1.2":       true,
+		"v2.0.0-rc1": true,
+		"^1.2":       false,
+		"1.x":        false,
+		">=1.0":      false,
+		"1.0 || 2.0": false,
+	}
+	for s, want := range tests {
+		c, err := ParseConstraint(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.IsExact() != want {
+			t.Errorf("ParseConstraint(%q).IsExact() = %v, want %v", s, !want, want)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1.2.0", "1.2.0", true},
+		{"1.2", "v1.2.0", true},
+		{"1.2.0", "1.2.1", false},
+		{"r123", "r123", true},
+		{"r123", "r999", false},
+		{"1.0~beta", "1.0", false},
+		{"1.0", "1.0~beta", false},
+		{"2024.01.05", "2024.1.5", true},
+	}
+	for _, tt := range tests {
+		if got := Equal(tt.a, tt.b); got != tt.want {
+			t.Errorf("Equal(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMaxSatisfying(t *testing.T) {
+	versions := []string{"1.2.0", "1.10.1", "2.0.0-rc.1", "1.9.9", "2.1.0", "0.9"}
+
+	tests := []struct {
+		constraint, want string
+	}{
+		{"^1.2", "1.10.1"},
+		{"~1.9", "1.9.9"},
+		{"*", "2.1.0"},
+		{"<1.0", "0.9"},
+		{">=2.0.0-rc.1 <2.1", "2.0.0-rc.1"},
+		{"^3", ""},
+	}
+	for _, tt := range tests {
+		got, err := MaxSatisfying(versions, tt.constraint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("MaxSatisfying(%q) = %q, want %q", tt.constraint, got, tt.want)
+		}
+	}
+}
+
+func TestIsAny(t *testing.T) {
+	tests := map[string]bool{
+		"*":       true,
+		"x":       true,
+		"^1 || *": true,
+		"^1":      false,
+		"1.2.3":   false,
+	}
+	for s, want := range tests {
+		c, err := ParseConstraint(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.IsAny() != want {
+			t.Errorf("ParseConstraint(%q).IsAny() = %v, want %v", s, !want, want)
+		}
+	}
+}